@@ -0,0 +1,220 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+const defaultBulkConcurrency = 4
+
+// BulkUpdate describes the changes applied to each transaction by
+// [Client.BulkUpdate] and [Client.BulkUpdateSeq].
+type BulkUpdate struct {
+	// AddTags and RemoveTags are tag labels to add to and remove from each
+	// transaction.
+	AddTags    []string
+	RemoveTags []string
+
+	// Category is the identifier of a category to set on each transaction.
+	// ClearCategory removes the category instead. Transactions which are not
+	// categorizable fail with an error matching [ErrNotCategorizable].
+	Category      string
+	ClearCategory bool
+
+	// Concurrency is the number of transactions updated at once. It defaults
+	// to 4.
+	Concurrency int
+}
+
+func (u BulkUpdate) validate() error {
+	if u.Category != "" && u.ClearCategory {
+		return errors.New("category cannot be both set and cleared")
+	}
+	if u.Category == "" && !u.ClearCategory && len(u.AddTags) == 0 && len(u.RemoveTags) == 0 {
+		return errors.New("no changes to apply")
+	}
+	return nil
+}
+
+func (u BulkUpdate) changesCategory() bool {
+	return u.Category != "" || u.ClearCategory
+}
+
+// BulkResult is the outcome of a bulk update for a single transaction.
+type BulkResult struct {
+	TransactionID string
+	// Err is nil if every change was applied to the transaction.
+	Err error
+}
+
+// BulkReport is the outcome of a bulk update, with one result per
+// transaction in the order they were given.
+type BulkReport struct {
+	Results []BulkResult
+}
+
+// Failed returns the results of transactions which could not be updated.
+func (r *BulkReport) Failed() []BulkResult {
+	var failed []BulkResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns the errors of every failed transaction joined together, or nil
+// if all transactions were updated.
+func (r *BulkReport) Err() error {
+	var errs []error
+	for _, res := range r.Failed() {
+		errs = append(errs, fmt.Errorf("transaction %s: %w", res.TransactionID, res.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// bulkItem is a transaction to update. The resource is only known when
+// updating transactions from an iterator.
+type bulkItem struct {
+	id          string
+	transaction *oapi.TransactionResource
+}
+
+// BulkUpdate applies update to each of the transactions identified by
+// transactionIDs, using a bounded pool of workers. Requests made by the workers
+// are subject to the client's [WithRateLimit] and [WithRetryPolicy] options,
+// which should be used to stay within the API's rate limits.
+//
+// Failures of individual transactions are recorded in the returned report;
+// an error is only returned if update is invalid or ctx is cancelled.
+func (c *Client) BulkUpdate(ctx context.Context, transactionIDs []string, update BulkUpdate) (*BulkReport, error) {
+	c.logger.Info("BulkUpdate", "transactions", len(transactionIDs))
+	return c.bulkUpdate(ctx, func(yield func(bulkItem, error) bool) {
+		for _, id := range transactionIDs {
+			if !yield(bulkItem{id: id}, nil) {
+				return
+			}
+		}
+	}, update)
+}
+
+// BulkUpdateSeq is like [Client.BulkUpdate], taking the transactions to update
+// from an iterator such as that returned by [Client.Transactions]. Transactions
+// are updated as the iterator yields them. An error yielded by the iterator
+// stops the update and is returned along with the report so far.
+func (c *Client) BulkUpdateSeq(ctx context.Context, transactions iter.Seq2[oapi.TransactionResource, error], update BulkUpdate) (*BulkReport, error) {
+	c.logger.Info("BulkUpdateSeq")
+	return c.bulkUpdate(ctx, func(yield func(bulkItem, error) bool) {
+		for t, err := range transactions {
+			if !yield(bulkItem{id: t.Id, transaction: &t}, err) {
+				return
+			}
+		}
+	}, update)
+}
+
+func (c *Client) bulkUpdate(ctx context.Context, items iter.Seq2[bulkItem, error], update BulkUpdate) (*BulkReport, error) {
+	if err := update.validate(); err != nil {
+		return nil, fmt.Errorf("invalid bulk update: %w", err)
+	}
+
+	workers := update.Concurrency
+	if workers <= 0 {
+		workers = defaultBulkConcurrency
+	}
+
+	type job struct {
+		item   bulkItem
+		result *BulkResult
+	}
+
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.result.Err = c.applyBulkUpdate(ctx, j.item, update)
+				if j.result.Err != nil {
+					c.logger.Warn("bulk update failed", "transactionID", j.item.id, "err", j.result.Err)
+				}
+			}
+		}()
+	}
+
+	// workers only write to results through the pointers they are sent, so
+	// the slice itself is owned by this goroutine
+	var results []*BulkResult
+	var err error
+dispatch:
+	for item, iterErr := range items {
+		if iterErr != nil {
+			err = iterErr
+			break
+		}
+		res := &BulkResult{TransactionID: item.id}
+		select {
+		case jobs <- job{item: item, result: res}:
+			results = append(results, res)
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &BulkReport{Results: make([]BulkResult, 0, len(results))}
+	for _, res := range results {
+		report.Results = append(report.Results, *res)
+	}
+	c.logger.Info("bulk update complete", "transactions", len(report.Results), "failed", len(report.Failed()))
+
+	return report, err
+}
+
+// applyBulkUpdate applies update to a single transaction.
+func (c *Client) applyBulkUpdate(ctx context.Context, item bulkItem, update BulkUpdate) error {
+	if update.changesCategory() {
+		if item.transaction == nil {
+			if err := c.checkCategorizable(ctx, item.id); err != nil {
+				return err
+			}
+		} else if !item.transaction.Attributes.IsCategorizable {
+			return fmt.Errorf("error categorizing transaction %s: %w", item.id, ErrNotCategorizable)
+		}
+
+		var category *oapi.CategoryInputResourceIdentifier
+		if !update.ClearCategory {
+			category = &oapi.CategoryInputResourceIdentifier{Id: update.Category, Type: "categories"}
+		}
+		if err := c.updateCategory(ctx, item.id, category); err != nil {
+			return err
+		}
+	}
+	if err := c.AddTags(ctx, item.id, update.AddTags...); err != nil {
+		return err
+	}
+	return c.RemoveTags(ctx, item.id, update.RemoveTags...)
+}
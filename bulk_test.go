@@ -0,0 +1,106 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package upgo_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/upgotest"
+)
+
+func tags(t oapi.TransactionResource) []string {
+	var tags []string
+	for _, tag := range t.Relationships.Tags.Data {
+		tags = append(tags, tag.Id)
+	}
+	return tags
+}
+
+func TestBulkUpdate(t *testing.T) {
+	ts := transactions(10)
+	upgotest.AddTags(&ts[0], "old")
+	srv, c := newTestServer(t, upgotest.Dataset{Transactions: ts})
+
+	txIDs := append(ids(ts), "missing")
+	report, err := c.BulkUpdate(context.Background(), txIDs, upgo.BulkUpdate{
+		AddTags:    []string{"coffee"},
+		RemoveTags: []string{"old"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Results) != len(txIDs) {
+		t.Fatalf("got %d results, want %d", len(report.Results), len(txIDs))
+	}
+	for i, res := range report.Results {
+		if res.TransactionID != txIDs[i] {
+			t.Errorf("result %d is for %s, want %s", i, res.TransactionID, txIDs[i])
+		}
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].TransactionID != "missing" || !errors.Is(failed[0].Err, upgo.ErrNotFound) {
+		t.Errorf("got failures %v, want missing transaction not found", failed)
+	}
+	if report.Err() == nil {
+		t.Error("report has no error")
+	}
+
+	for _, id := range txIDs[:len(txIDs)-1] {
+		tr, _ := srv.Transaction(id)
+		if got := tags(tr); !slices.Equal(got, []string{"coffee"}) {
+			t.Errorf("transaction %s has tags %v, want [coffee]", id, got)
+		}
+	}
+}
+
+func TestBulkUpdateCategory(t *testing.T) {
+	ts := transactions(3)
+	ts[1].Attributes.IsCategorizable = false
+	srv, c := newTestServer(t, upgotest.Dataset{
+		Transactions: ts,
+		Categories:   []oapi.CategoryResource{upgotest.Category("restaurants-and-cafes", "Restaurants & Cafes", "")},
+	})
+
+	report, err := c.BulkUpdate(context.Background(), ids(ts), upgo.BulkUpdate{Category: "restaurants-and-cafes", Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].TransactionID != "t2" || !errors.Is(failed[0].Err, upgo.ErrNotCategorizable) {
+		t.Errorf("got failures %v, want t2 not categorizable", failed)
+	}
+	for _, id := range []string{"t1", "t3"} {
+		tr, _ := srv.Transaction(id)
+		if cat := tr.Relationships.Category.Data; cat == nil || cat.Id != "restaurants-and-cafes" {
+			t.Errorf("transaction %s not categorized", id)
+		}
+	}
+}
+
+func TestBulkUpdateInvalid(t *testing.T) {
+	_, c := newTestServer(t, upgotest.Dataset{})
+
+	if _, err := c.BulkUpdate(context.Background(), []string{"t1"}, upgo.BulkUpdate{}); err == nil {
+		t.Error("empty update accepted")
+	}
+	if _, err := c.BulkUpdate(context.Background(), []string{"t1"}, upgo.BulkUpdate{Category: "c", ClearCategory: true}); err == nil {
+		t.Error("update setting and clearing category accepted")
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// GetCategories returns all categories, optionally filtered by [oapi.GetCategoriesParams].
+// The list of categories is not paginated.
+func (c *Client) GetCategories(ctx context.Context, params *oapi.GetCategoriesParams) ([]oapi.CategoryResource, error) {
+	c.logger.Info("GetCategories")
+	resp, err := c.upClient.GetCategoriesWithResponse(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse("categories", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return resp.JSON200.Data, nil
+}
+
+// CategoryTree returns the hierarchy of all categories. The tree is fetched
+// once and then cached for the life of the Client, as categories are fixed
+// by Up.
+func (c *Client) CategoryTree(ctx context.Context) (*CategoryTree, error) {
+	c.categoriesMu.Lock()
+	defer c.categoriesMu.Unlock()
+
+	if c.categories != nil {
+		return c.categories, nil
+	}
+
+	cats, err := c.GetCategories(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.categories = NewCategoryTree(cats)
+	return c.categories, nil
+}
+
+// CategoryTree is the hierarchy of parent and child categories, built from
+// the relationships of each [oapi.CategoryResource]. It is safe for concurrent
+// use as it is not modified once built.
+type CategoryTree struct {
+	byID  map[string]*oapi.CategoryResource
+	roots []string
+}
+
+// NewCategoryTree builds a CategoryTree from cats, typically the result of
+// [Client.GetCategories] without a filter.
+func NewCategoryTree(cats []oapi.CategoryResource) *CategoryTree {
+	cats = slices.Clone(cats)
+	t := &CategoryTree{
+		byID: make(map[string]*oapi.CategoryResource, len(cats)),
+	}
+	for i := range cats {
+		cat := &cats[i]
+		t.byID[cat.Id] = cat
+		if cat.Relationships.Parent.Data == nil {
+			t.roots = append(t.roots, cat.Id)
+		}
+	}
+	return t
+}
+
+// Get returns the category identified by id.
+func (t *CategoryTree) Get(id string) (*oapi.CategoryResource, bool) {
+	cat, ok := t.byID[id]
+	return cat, ok
+}
+
+// Name returns the name of the category identified by id, or an empty string
+// if there is no such category.
+func (t *CategoryTree) Name(id string) string {
+	if cat, ok := t.byID[id]; ok {
+		return cat.Attributes.Name
+	}
+	return ""
+}
+
+// ByName returns the category with the given name, ignoring case.
+func (t *CategoryTree) ByName(name string) (*oapi.CategoryResource, bool) {
+	for _, cat := range t.byID {
+		if strings.EqualFold(cat.Attributes.Name, name) {
+			return cat, true
+		}
+	}
+	return nil, false
+}
+
+// Parent returns the parent of the category identified by id. It returns
+// false if the category is unknown or is itself a parent category.
+func (t *CategoryTree) Parent(id string) (*oapi.CategoryResource, bool) {
+	cat, ok := t.byID[id]
+	if !ok || cat.Relationships.Parent.Data == nil {
+		return nil, false
+	}
+	return t.Get(cat.Relationships.Parent.Data.Id)
+}
+
+// Roots returns the top level parent categories.
+func (t *CategoryTree) Roots() []*oapi.CategoryResource {
+	return t.lookup(t.roots)
+}
+
+// Children returns the immediate children of the category identified by id.
+func (t *CategoryTree) Children(id string) []*oapi.CategoryResource {
+	cat, ok := t.byID[id]
+	if !ok {
+		return nil
+	}
+	ids := make([]string, 0, len(cat.Relationships.Children.Data))
+	for _, child := range cat.Relationships.Children.Data {
+		ids = append(ids, child.Id)
+	}
+	return t.lookup(ids)
+}
+
+// Descendants returns an iterator over every category below the category
+// identified by id, depth first. Each category is paired with its depth
+// below id, starting at 1 for immediate children.
+func (t *CategoryTree) Descendants(id string) iter.Seq2[*oapi.CategoryResource, int] {
+	return func(yield func(*oapi.CategoryResource, int) bool) {
+		t.walk(t.Children(id), 1, yield)
+	}
+}
+
+// All returns an iterator over every category in the tree, depth first,
+// paired with its depth starting at 0 for parent categories.
+func (t *CategoryTree) All() iter.Seq2[*oapi.CategoryResource, int] {
+	return func(yield func(*oapi.CategoryResource, int) bool) {
+		t.walk(t.Roots(), 0, yield)
+	}
+}
+
+func (t *CategoryTree) walk(cats []*oapi.CategoryResource, depth int, yield func(*oapi.CategoryResource, int) bool) bool {
+	for _, cat := range cats {
+		if !yield(cat, depth) {
+			return false
+		}
+		if !t.walk(t.Children(cat.Id), depth+1, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Render writes the hierarchy to w, one category per line, indenting
+// children beneath their parent.
+func (t *CategoryTree) Render(w io.Writer) error {
+	for cat, depth := range t.All() {
+		if _, err := fmt.Fprintf(w, "%s%s (%s)\n", strings.Repeat("  ", depth), cat.Attributes.Name, cat.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the hierarchy as rendered by [CategoryTree.Render].
+func (t *CategoryTree) String() string {
+	var b strings.Builder
+	_ = t.Render(&b)
+	return b.String()
+}
+
+func (t *CategoryTree) lookup(ids []string) []*oapi.CategoryResource {
+	cats := make([]*oapi.CategoryResource, 0, len(ids))
+	for _, id := range ids {
+		if cat, ok := t.byID[id]; ok {
+			cats = append(cats, cat)
+		}
+	}
+	return cats
+}
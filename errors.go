@@ -0,0 +1,145 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// Sentinel errors which an [APIError] can be matched against with [errors.Is].
+var (
+	// ErrUnauthorized is matched by HTTP 401 responses, e.g. when the API token is invalid.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound is matched by HTTP 404 responses, returned when the requested resource does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is matched by HTTP 429 responses.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrInvalidFilter is matched by responses with an error relating to a `filter[...]` query parameter.
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// ErrNotCategorizable is returned when attempting to change the category of a
+// transaction which does not support categories.
+var ErrNotCategorizable = errors.New("transaction is not categorizable")
+
+// APIError is returned when the Up API responds with an unexpected HTTP status.
+// Where the response body could be decoded, Errors holds each [oapi.ErrorObject] it contained.
+//
+// Use [errors.As] to access the details, or [errors.Is] to match against
+// [ErrUnauthorized], [ErrNotFound], [ErrRateLimited] and [ErrInvalidFilter].
+type APIError struct {
+	StatusCode int
+	Errors     []oapi.ErrorObject
+}
+
+// newAPIError returns an APIError for statusCode, decoding body as an
+// [oapi.ErrorResponse] if possible.
+func newAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode}
+	var resp oapi.ErrorResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		e.Errors = resp.Errors
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "API returned HTTP %d", e.StatusCode)
+	for i, o := range e.Errors {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(o.Title)
+		if o.Detail != "" {
+			b.WriteString(": " + o.Detail)
+		}
+		if o.Source != nil {
+			if o.Source.Parameter != nil {
+				fmt.Fprintf(&b, " (parameter %s)", *o.Source.Parameter)
+			}
+			if o.Source.Pointer != nil {
+				fmt.Fprintf(&b, " (pointer %s)", *o.Source.Pointer)
+			}
+		}
+	}
+	return b.String()
+}
+
+// HTTPStatusCode returns StatusCode, so that the status can be inspected by
+// packages which do not import this one, such as
+// [github.com/porjo/upgo/webhook].
+func (e *APIError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// Is reports whether e matches one of the sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrInvalidFilter:
+		for _, o := range e.Errors {
+			if o.Source != nil && o.Source.Parameter != nil && strings.HasPrefix(*o.Source.Parameter, "filter[") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkResponse returns an error if a response has an unexpected status code or
+// is missing its payload. what describes the requested resource.
+// Unexpected status codes result in an error wrapping an [APIError] decoded from body.
+//
+// The methods fetching a single resource, such as [Client.GetAccount], each
+// repeat the same few lines around it: the generated response types share no
+// accessor for their payload, so a generic helper could not reach it.
+func checkResponse(what string, statusCode int, body []byte, hasPayload bool) error {
+	return checkPayload("getting "+what, http.StatusOK, statusCode, body, hasPayload)
+}
+
+// checkPayload is like checkResponse for operations which succeed with a
+// status other than 200. what describes the attempted operation.
+func checkPayload(what string, expected, statusCode int, body []byte, hasPayload bool) error {
+	if statusCode != expected {
+		return fmt.Errorf("error %s: %w", what, newAPIError(statusCode, body))
+	}
+	if !hasPayload {
+		return fmt.Errorf("error %s: response is nil", what)
+	}
+	return nil
+}
+
+// checkNoContent returns an error if a response to a request which returns no
+// payload was not successful. what describes the attempted operation.
+func checkNoContent(what string, statusCode int, body []byte) error {
+	if statusCode < 200 || statusCode > 299 {
+		return fmt.Errorf("error %s: %w", what, newAPIError(statusCode, body))
+	}
+	return nil
+}
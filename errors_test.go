@@ -0,0 +1,170 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/upgotest"
+)
+
+func TestAPIError(t *testing.T) {
+	srv, c := newTestServer(t, upgotest.Dataset{Transactions: transactions(1)})
+	ctx := context.Background()
+
+	unauthorized, err := upgo.NewClient(upgo.WithBaseURL(srv.URL), upgo.WithToken("wrong"), upgo.WithoutPing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := oapi.TransactionStatusEnum("BOGUS")
+	category := "missing"
+	pageSize := 1000
+
+	sentinels := []error{upgo.ErrUnauthorized, upgo.ErrNotFound, upgo.ErrRateLimited, upgo.ErrInvalidFilter}
+	tests := []struct {
+		name       string
+		call       func() error
+		wantStatus int
+		wantIs     []error
+		wantMsg    string
+	}{
+		{
+			name: "unauthorized",
+			call: func() error {
+				_, err := unauthorized.GetAccounts(ctx)
+				return err
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantIs:     []error{upgo.ErrUnauthorized},
+			wantMsg:    "API returned HTTP 401: Not Authorized: ",
+		},
+		{
+			name: "not found",
+			call: func() error {
+				_, err := c.GetTransaction(ctx, "missing")
+				return err
+			},
+			wantStatus: http.StatusNotFound,
+			wantIs:     []error{upgo.ErrNotFound},
+			wantMsg:    "API returned HTTP 404: Not Found: The transaction could not be found.",
+		},
+		{
+			name: "invalid filter",
+			call: func() error {
+				_, err := c.GetTransactions(ctx, &oapi.GetTransactionsParams{FilterStatus: &status})
+				return err
+			},
+			wantStatus: http.StatusBadRequest,
+			wantIs:     []error{upgo.ErrInvalidFilter},
+			wantMsg:    "(parameter filter[status])",
+		},
+		{
+			name: "filter referring to missing resource",
+			call: func() error {
+				_, err := c.GetTransactions(ctx, &oapi.GetTransactionsParams{FilterCategory: &category})
+				return err
+			},
+			wantStatus: http.StatusNotFound,
+			wantIs:     []error{upgo.ErrNotFound, upgo.ErrInvalidFilter},
+			wantMsg:    "(parameter filter[category])",
+		},
+		{
+			name: "invalid parameter other than a filter",
+			call: func() error {
+				_, err := c.GetTransactions(ctx, &oapi.GetTransactionsParams{PageSize: &pageSize})
+				return err
+			},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "(parameter page[size])",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var apiErr *upgo.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got error %v, want APIError", err)
+			}
+			if apiErr.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", apiErr.StatusCode, tt.wantStatus)
+			}
+			for _, target := range sentinels {
+				if got, want := errors.Is(err, target), slices.Contains(tt.wantIs, target); got != want {
+					t.Errorf("errors.Is(err, %v) = %v, want %v", target, got, want)
+				}
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("got message %q, want it to contain %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestAPIErrorUndecodableBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantIs      error
+	}{
+		{"rate limited", http.StatusTooManyRequests, "text/plain", "slow down", upgo.ErrRateLimited},
+		{"HTML error page", http.StatusBadGateway, "text/html", "<html><body>Bad Gateway</body></html>", nil},
+		{"empty body", http.StatusServiceUnavailable, "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			c, err := upgo.NewClient(upgo.WithBaseURL(srv.URL), upgo.WithoutPing())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = c.GetAccount(context.Background(), "account")
+			var apiErr *upgo.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got error %v, want APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || len(apiErr.Errors) != 0 {
+				t.Errorf("got status %d with %d error objects, want %d and none", apiErr.StatusCode, len(apiErr.Errors), tt.status)
+			}
+			if want := fmt.Sprintf("API returned HTTP %d", tt.status); !strings.HasSuffix(err.Error(), want) {
+				t.Errorf("got message %q, want it to end with %q", err, want)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("got error %v, want %v", err, tt.wantIs)
+			}
+			if errors.Is(err, upgo.ErrNotFound) || errors.Is(err, upgo.ErrInvalidFilter) {
+				t.Errorf("error %v matches an unrelated sentinel", err)
+			}
+		})
+	}
+}
@@ -78,6 +78,11 @@ func main() {
 		log.Fatal(err)
 	}
 
+	tree, err := c.CategoryTree(context.TODO())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	fmt.Println("Transactions")
 
 	for _, t := range trans {
@@ -131,6 +136,10 @@ func main() {
 		}
 		amount := float64(total) / upgo.BaseUnitDivisor
 
+		if name := tree.Name(cat); name != "" {
+			cat = name
+		}
+
 		fmt.Println(cat)
 		fmt.Printf("-------------------------------- ----------------\n")
 		fmt.Print(catStr)
@@ -0,0 +1,74 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/webhook"
+)
+
+func main() {
+	interval := flag.Duration("interval", 0, "time between polls (default 1m)")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	token, ok := os.LookupEnv("API_TOKEN")
+	if !ok {
+		log.Fatal("environment variable API_TOKEN not set")
+	}
+
+	c, err := upgo.NewClient(
+		upgo.WithLogger(logger),
+		upgo.WithToken(token),
+		upgo.WithRetryPolicy(upgo.DefaultRetryPolicy),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the same handlers could be used with a webhook.Handler
+	d := webhook.NewDispatcher(webhook.WithLogger(logger))
+	d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+		logger.Info("transaction created", "transactionID", ev.TransactionID(), "description", ev.Transaction.Attributes.Description, "amount", ev.Transaction.Attributes.Amount.Value)
+		return nil
+	})
+	d.OnTransactionSettled(func(ctx context.Context, ev *webhook.Event) error {
+		logger.Info("transaction settled", "transactionID", ev.TransactionID())
+		return nil
+	})
+	d.OnTransactionDeleted(func(ctx context.Context, ev *webhook.Event) error {
+		logger.Info("transaction deleted", "transactionID", ev.TransactionID())
+		return nil
+	})
+	d.OnTransactionAmountChanged(func(ctx context.Context, ev *webhook.Event) error {
+		logger.Info("transaction amount changed", "transactionID", ev.TransactionID(), "from", ev.Previous.Attributes.Amount.Value, "to", ev.Transaction.Attributes.Amount.Value)
+		return nil
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := c.NewPoller(d, upgo.PollerConfig{Interval: *interval})
+	if err := p.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dedup := flag.String("dedup", "", "file recording handled event IDs, so that redelivered events are ignored")
+	journal := flag.String("journal", "", "file recording every received event, so that events can be replayed")
+	replay := flag.Bool("replay", false, "replay events from the journal through the handlers, then exit")
+	replaySince := flag.String("replay-since", "", "replay events received at or after this RFC 3339 time")
+	replayUntil := flag.String("replay-until", "", "replay events received before this RFC 3339 time")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	secret, ok := os.LookupEnv("WEBHOOK_SECRET")
+	if !ok {
+		log.Fatal("environment variable WEBHOOK_SECRET not set")
+	}
+
+	opts := []webhook.Option{webhook.WithLogger(logger)}
+
+	// with an API token, fetch the transaction each event relates to
+	if token, ok := os.LookupEnv("API_TOKEN"); ok {
+		c, err := upgo.NewClient(
+			upgo.WithLogger(logger),
+			upgo.WithToken(token),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, webhook.WithTransactionResolver(c))
+	}
+
+	// replayed events have usually been handled already, so are not deduplicated
+	if *dedup != "" && !*replay {
+		store, err := webhook.OpenFileStore(*dedup, 7*24*time.Hour)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer store.Close()
+		opts = append(opts, webhook.WithDedup(store))
+	}
+
+	d := webhook.NewDispatcher(opts...)
+	d.OnPing(func(ctx context.Context, ev *webhook.Event) error {
+		logger.Info("ping", "id", ev.Id)
+		return nil
+	})
+	d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+		if ev.Transaction != nil {
+			logger.Info("transaction created", "transactionID", ev.TransactionID(), "description", ev.Transaction.Attributes.Description, "amount", ev.Transaction.Attributes.Amount.Value)
+			return nil
+		}
+		logger.Info("transaction created", "transactionID", ev.TransactionID())
+		return nil
+	})
+	d.OnTransactionSettled(func(ctx context.Context, ev *webhook.Event) error {
+		logger.Info("transaction settled", "transactionID", ev.TransactionID())
+		return nil
+	})
+	d.OnTransactionDeleted(func(ctx context.Context, ev *webhook.Event) error {
+		logger.Info("transaction deleted", "transactionID", ev.TransactionID())
+		return nil
+	})
+
+	if *replay {
+		if *journal == "" {
+			log.Fatal("-replay requires -journal")
+		}
+		since, err := parseTime(*replaySince)
+		if err != nil {
+			log.Fatal(err)
+		}
+		until, err := parseTime(*replayUntil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := d.Replay(context.Background(), *journal, webhook.ReplayOptions{
+			Since:     since,
+			Until:     until,
+			SecretKey: secret,
+		})
+		logger.Info("replayed events", "count", n)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var hopts []webhook.HandlerOption
+	if *journal != "" {
+		j, err := webhook.OpenJournal(*journal)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer j.Close()
+		hopts = append(hopts, webhook.WithJournal(j))
+	}
+
+	http.Handle("/webhook", webhook.NewHandler(secret, d, hopts...))
+
+	logger.Info("listening", "addr", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,208 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// WebhookMonitorConfig configures a [WebhookMonitor]. Zero values are
+// replaced with the defaults noted on each field.
+type WebhookMonitorConfig struct {
+	// Interval is the time between checks made by [WebhookMonitor.Run].
+	// It defaults to 5 minutes.
+	Interval time.Duration
+
+	// Window is the number of most recent delivery logs of each webhook
+	// which are considered. It defaults to 50.
+	Window int
+
+	// MinSuccessRate is the fraction of deliveries within the window which
+	// must succeed for a webhook to be healthy. It defaults to 0.9, and a
+	// negative value disables the check.
+	MinSuccessRate float64
+
+	// MaxFailureStreak is the number of most recent consecutive failed
+	// deliveries at which a webhook becomes unhealthy. It defaults to 3, and
+	// a negative value disables the check.
+	MaxFailureStreak int
+
+	// OnUnhealthy, if set, is called with the health of each unhealthy
+	// webhook found by a check.
+	OnUnhealthy func(ctx context.Context, health WebhookHealth)
+}
+
+// WebhookHealth summarises the recent delivery logs of a webhook.
+type WebhookHealth struct {
+	Webhook oapi.WebhookResource
+
+	// Deliveries is the number of delivery logs considered, of which
+	// Delivered were successful.
+	Deliveries int
+	Delivered  int
+
+	// SuccessRate is Delivered as a fraction of Deliveries, or 1 if there
+	// were no deliveries.
+	SuccessRate float64
+
+	// FailureStreak is the number of most recent consecutive deliveries which
+	// were UNDELIVERABLE or BAD_RESPONSE_CODE.
+	FailureStreak int
+
+	// LastStatus, LastDeliveryAt and LastStatusCode describe the most recent
+	// delivery. LastStatusCode is zero if no response was received.
+	LastStatus     oapi.WebhookDeliveryStatusEnum
+	LastDeliveryAt time.Time
+	LastStatusCode int
+
+	// Problems describes why the webhook is unhealthy. It is empty for a
+	// healthy webhook.
+	Problems []string
+}
+
+// Healthy reports whether no problems were found with the webhook.
+func (h WebhookHealth) Healthy() bool {
+	return len(h.Problems) == 0
+}
+
+// WebhookMonitor periodically checks the delivery logs of every webhook,
+// reporting those which are unhealthy.
+type WebhookMonitor struct {
+	client *Client
+	cfg    WebhookMonitorConfig
+}
+
+// NewWebhookMonitor returns a WebhookMonitor configured by cfg.
+func (c *Client) NewWebhookMonitor(cfg WebhookMonitorConfig) *WebhookMonitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 50
+	}
+	if cfg.MinSuccessRate == 0 {
+		cfg.MinSuccessRate = 0.9
+	}
+	if cfg.MaxFailureStreak == 0 {
+		cfg.MaxFailureStreak = 3
+	}
+	return &WebhookMonitor{client: c, cfg: cfg}
+}
+
+// Run checks the webhooks immediately and then at each interval until ctx is
+// done, returning the context's error. Errors from individual checks are
+// logged and do not stop the monitor.
+func (m *WebhookMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Check(ctx); err != nil && ctx.Err() == nil {
+			m.client.logger.Error("webhook health check failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check fetches the delivery logs of every webhook once, returning the health
+// of each. Unhealthy webhooks are logged and passed to OnUnhealthy. Webhooks
+// whose logs cannot be fetched are left out of the results, and their errors
+// are joined together and returned once the rest have been checked.
+func (m *WebhookMonitor) Check(ctx context.Context) ([]WebhookHealth, error) {
+	logger := m.client.logger
+
+	webhooks, err := m.client.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := min(m.cfg.Window, 100)
+	results := make([]WebhookHealth, 0, len(webhooks))
+	var errs []error
+	for _, wh := range webhooks {
+		pager := m.client.WebhookLogsPager(wh.Id, &oapi.GetWebhooksWebhookIdLogsParams{PageSize: &pageSize})
+		logs, err := collect(ctx, pager, pageConfig{maxRecords: m.cfg.Window})
+		if err != nil {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			logger.Warn("error getting webhook logs", "id", wh.Id, "err", err)
+			errs = append(errs, fmt.Errorf("error getting logs of webhook %s: %w", wh.Id, err))
+			continue
+		}
+
+		health := m.assess(wh, logs)
+		results = append(results, health)
+
+		if health.Healthy() {
+			logger.Info("webhook healthy", "id", wh.Id, "url", wh.Attributes.Url, "deliveries", health.Deliveries, "successRate", health.SuccessRate)
+			continue
+		}
+		logger.Warn("webhook unhealthy", "id", wh.Id, "url", wh.Attributes.Url, "deliveries", health.Deliveries, "successRate", health.SuccessRate,
+			"failureStreak", health.FailureStreak, "lastStatus", health.LastStatus, "lastStatusCode", health.LastStatusCode, "problems", health.Problems)
+		if m.cfg.OnUnhealthy != nil {
+			m.cfg.OnUnhealthy(ctx, health)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// assess summarises logs, which the API orders newest first.
+func (m *WebhookMonitor) assess(wh oapi.WebhookResource, logs []oapi.WebhookDeliveryLogResource) WebhookHealth {
+	h := WebhookHealth{
+		Webhook:     wh,
+		Deliveries:  len(logs),
+		SuccessRate: 1,
+	}
+	if len(logs) == 0 {
+		return h
+	}
+
+	last := logs[0].Attributes
+	h.LastStatus = last.DeliveryStatus
+	h.LastDeliveryAt = last.CreatedAt
+	if last.Response != nil {
+		h.LastStatusCode = last.Response.StatusCode
+	}
+
+	streak := true
+	for _, l := range logs {
+		if l.Attributes.DeliveryStatus == oapi.DELIVERED {
+			h.Delivered++
+			streak = false
+		} else if streak {
+			h.FailureStreak++
+		}
+	}
+	h.SuccessRate = float64(h.Delivered) / float64(h.Deliveries)
+
+	if m.cfg.MinSuccessRate > 0 && h.SuccessRate < m.cfg.MinSuccessRate {
+		h.Problems = append(h.Problems, fmt.Sprintf("success rate %.0f%% is below %.0f%%", h.SuccessRate*100, m.cfg.MinSuccessRate*100))
+	}
+	if m.cfg.MaxFailureStreak > 0 && h.FailureStreak >= m.cfg.MaxFailureStreak {
+		h.Problems = append(h.Problems, fmt.Sprintf("last %d deliveries failed, most recently %s", h.FailureStreak, h.LastStatus))
+	}
+	return h
+}
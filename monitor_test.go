@@ -0,0 +1,78 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/porjo/upgo"
+)
+
+func TestWebhookMonitorCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/webhooks":
+			fmt.Fprint(w, `{"data":[{"id":"broken","attributes":{"url":"https://a"}},{"id":"flaky","attributes":{"url":"https://b"}}],"links":{}}`)
+		case "/webhooks/broken/logs":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"errors":[{"status":"500","title":"Internal Server Error"}]}`)
+		case "/webhooks/flaky/logs":
+			// one failure followed by successes: a poor success rate but no streak
+			fmt.Fprint(w, `{"data":[{"attributes":{"deliveryStatus":"UNDELIVERABLE"}},{"attributes":{"deliveryStatus":"DELIVERED"}}],"links":{}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := upgo.NewClient(upgo.WithBaseURL(srv.URL), upgo.WithoutPing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		minSuccessRate float64
+		wantUnhealthy  int
+	}{
+		{"default success rate", 0, 1},
+		{"success rate disabled", -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unhealthy := 0
+			m := c.NewWebhookMonitor(upgo.WebhookMonitorConfig{
+				MinSuccessRate: tt.minSuccessRate,
+				OnUnhealthy:    func(ctx context.Context, h upgo.WebhookHealth) { unhealthy++ },
+			})
+
+			results, err := m.Check(context.Background())
+			if err == nil {
+				t.Fatal("got nil error, want error for broken webhook")
+			}
+			if len(results) != 1 || results[0].Webhook.Id != "flaky" {
+				t.Fatalf("got %d results, want flaky webhook checked despite broken webhook", len(results))
+			}
+			if unhealthy != tt.wantUnhealthy {
+				t.Errorf("OnUnhealthy called %d times, want %d", unhealthy, tt.wantUnhealthy)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package oapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// The enum types generated from the spec (see overlay.yaml) must be JSON
+// strings when unmarshalled, but values outside the enum are accepted: the
+// spec lists the values currently returned, and a value added to the API must
+// not fail the decoding of every response carrying it. Callers which need a
+// known value check it with Valid.
+
+// Valid reports whether e is one of the defined [AccountTypeEnum] values.
+func (e AccountTypeEnum) Valid() bool {
+	return slices.Contains([]AccountTypeEnum{HOMELOAN, SAVER, TRANSACTIONAL}, e)
+}
+
+func (e *AccountTypeEnum) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, e, "AccountTypeEnum")
+}
+
+// Valid reports whether e is one of the defined [CardPurchaseMethodEnum] values.
+func (e CardPurchaseMethodEnum) Valid() bool {
+	return slices.Contains([]CardPurchaseMethodEnum{BARCODE, CARDDETAILS, CARDONFILE, CARDPIN, CONTACTLESS, ECOMMERCE, MAGNETICSTRIPE, OCR}, e)
+}
+
+func (e *CardPurchaseMethodEnum) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, e, "CardPurchaseMethodEnum")
+}
+
+// Valid reports whether e is one of the defined [OwnershipTypeEnum] values.
+func (e OwnershipTypeEnum) Valid() bool {
+	return slices.Contains([]OwnershipTypeEnum{INDIVIDUAL, JOINT}, e)
+}
+
+func (e *OwnershipTypeEnum) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, e, "OwnershipTypeEnum")
+}
+
+// Valid reports whether e is one of the defined [TransactionStatusEnum] values.
+func (e TransactionStatusEnum) Valid() bool {
+	return slices.Contains([]TransactionStatusEnum{HELD, SETTLED}, e)
+}
+
+func (e *TransactionStatusEnum) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, e, "TransactionStatusEnum")
+}
+
+// Valid reports whether e is one of the defined [WebhookDeliveryStatusEnum] values.
+func (e WebhookDeliveryStatusEnum) Valid() bool {
+	return slices.Contains([]WebhookDeliveryStatusEnum{BADRESPONSECODE, DELIVERED, UNDELIVERABLE}, e)
+}
+
+func (e *WebhookDeliveryStatusEnum) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, e, "WebhookDeliveryStatusEnum")
+}
+
+// Valid reports whether e is one of the defined [WebhookEventTypeEnum] values.
+func (e WebhookEventTypeEnum) Valid() bool {
+	return slices.Contains([]WebhookEventTypeEnum{PING, TRANSACTIONCREATED, TRANSACTIONDELETED, TRANSACTIONSETTLED}, e)
+}
+
+func (e *WebhookEventTypeEnum) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, e, "WebhookEventTypeEnum")
+}
+
+type enum interface {
+	~string
+	Valid() bool
+}
+
+// unmarshalEnum decodes a JSON string into e, returning an error if the value
+// is not a string. A JSON null leaves e unchanged.
+func unmarshalEnum[E enum](data []byte, e *E, name string) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("error decoding %s: %w", name, err)
+	}
+	*e = E(s)
+	return nil
+}
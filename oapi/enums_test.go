@@ -0,0 +1,41 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package oapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalEnum(t *testing.T) {
+	var tr TransactionResource
+	body := `{"attributes":{"status":"SETTLED","cardPurchaseMethod":{"method":"SOMETHING_NEW","cardNumberSuffix":"1234"}}}`
+	if err := json.Unmarshal([]byte(body), &tr); err != nil {
+		t.Fatalf("unknown enum value rejected: %v", err)
+	}
+	if tr.Attributes.Status != SETTLED || !tr.Attributes.Status.Valid() {
+		t.Errorf("got status %q, want %q", tr.Attributes.Status, SETTLED)
+	}
+	if m := tr.Attributes.CardPurchaseMethod; m == nil || m.Method != "SOMETHING_NEW" || m.Method.Valid() {
+		t.Errorf("got card purchase method %+v, want invalid SOMETHING_NEW", m)
+	}
+
+	var e AccountTypeEnum = SAVER
+	if err := json.Unmarshal([]byte("null"), &e); err != nil || e != SAVER {
+		t.Errorf("null: got %q, %v, want %q unchanged", e, err, SAVER)
+	}
+	if err := json.Unmarshal([]byte("1"), &e); err == nil {
+		t.Error("non-string value accepted")
+	}
+}
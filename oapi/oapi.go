@@ -21,6 +21,52 @@ const (
 	Bearer_authScopes = "bearer_auth.Scopes"
 )
 
+// Defines values for AccountTypeEnum.
+const (
+	HOMELOAN      AccountTypeEnum = "HOME_LOAN"
+	SAVER         AccountTypeEnum = "SAVER"
+	TRANSACTIONAL AccountTypeEnum = "TRANSACTIONAL"
+)
+
+// Defines values for CardPurchaseMethodEnum.
+const (
+	BARCODE        CardPurchaseMethodEnum = "BAR_CODE"
+	CARDDETAILS    CardPurchaseMethodEnum = "CARD_DETAILS"
+	CARDONFILE     CardPurchaseMethodEnum = "CARD_ON_FILE"
+	CARDPIN        CardPurchaseMethodEnum = "CARD_PIN"
+	CONTACTLESS    CardPurchaseMethodEnum = "CONTACTLESS"
+	ECOMMERCE      CardPurchaseMethodEnum = "ECOMMERCE"
+	MAGNETICSTRIPE CardPurchaseMethodEnum = "MAGNETIC_STRIPE"
+	OCR            CardPurchaseMethodEnum = "OCR"
+)
+
+// Defines values for OwnershipTypeEnum.
+const (
+	INDIVIDUAL OwnershipTypeEnum = "INDIVIDUAL"
+	JOINT      OwnershipTypeEnum = "JOINT"
+)
+
+// Defines values for TransactionStatusEnum.
+const (
+	HELD    TransactionStatusEnum = "HELD"
+	SETTLED TransactionStatusEnum = "SETTLED"
+)
+
+// Defines values for WebhookDeliveryStatusEnum.
+const (
+	BADRESPONSECODE WebhookDeliveryStatusEnum = "BAD_RESPONSE_CODE"
+	DELIVERED       WebhookDeliveryStatusEnum = "DELIVERED"
+	UNDELIVERABLE   WebhookDeliveryStatusEnum = "UNDELIVERABLE"
+)
+
+// Defines values for WebhookEventTypeEnum.
+const (
+	PING               WebhookEventTypeEnum = "PING"
+	TRANSACTIONCREATED WebhookEventTypeEnum = "TRANSACTION_CREATED"
+	TRANSACTIONDELETED WebhookEventTypeEnum = "TRANSACTION_DELETED"
+	TRANSACTIONSETTLED WebhookEventTypeEnum = "TRANSACTION_SETTLED"
+)
+
 // AccountResource Provides information about an Up bank account.
 type AccountResource struct {
 	Attributes struct {
@@ -62,7 +108,7 @@ type AccountResource struct {
 
 // AccountTypeEnum Specifies the type of bank account. Currently returned values are
 // `SAVER`, `TRANSACTIONAL` and `HOME_LOAN`.
-type AccountTypeEnum = interface{}
+type AccountTypeEnum string
 
 // AttachmentResource defines model for AttachmentResource.
 type AttachmentResource struct {
@@ -110,7 +156,7 @@ type AttachmentResource struct {
 }
 
 // CardPurchaseMethodEnum Specifies the type of card charge.
-type CardPurchaseMethodEnum = interface{}
+type CardPurchaseMethodEnum string
 
 // CardPurchaseMethodObject Provides information about the card used for a transaction.
 type CardPurchaseMethodObject struct {
@@ -420,7 +466,7 @@ type NoteObject struct {
 
 // OwnershipTypeEnum Specifies the structure under which a bank account is owned. Currently
 // returned values are `INDIVIDUAL` and `JOINT`.
-type OwnershipTypeEnum = interface{}
+type OwnershipTypeEnum string
 
 // PingResponse Basic ping response to verify authentication.
 type PingResponse struct {
@@ -646,7 +692,7 @@ type TransactionResource struct {
 // Currently returned values are `HELD` and `SETTLED`. When a transaction is
 // held, its account’s `availableBalance` is affected. When a transaction is
 // settled, its account’s `currentBalance` is affected.
-type TransactionStatusEnum = interface{}
+type TransactionStatusEnum string
 
 // UpdateTransactionCategoryRequest Request to update the category associated with a transaction.
 type UpdateTransactionCategoryRequest struct {
@@ -714,7 +760,7 @@ type WebhookDeliveryLogResource struct {
 //   - **`UNDELIVERABLE`**: The webhook URL was not reachable, or timed out.
 //   - **`BAD_RESPONSE_CODE`**: The event was delivered to the webhook URL
 //     but a non-`200` response was received.
-type WebhookDeliveryStatusEnum = interface{}
+type WebhookDeliveryStatusEnum string
 
 // WebhookEventCallback Asynchronous callback request used for webhook event delivery.
 type WebhookEventCallback struct {
@@ -774,7 +820,7 @@ type WebhookEventResource struct {
 // WebhookEventTypeEnum Specifies the type of a webhook event. This can be used to determine what
 // action to take in response to the event, such as which relationships to
 // expect.
-type WebhookEventTypeEnum = interface{}
+type WebhookEventTypeEnum string
 
 // WebhookInputResource Represents a webhook specified as request input.
 type WebhookInputResource struct {
@@ -0,0 +1,345 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// ErrNoMorePages is returned by [Pager.Next] and [Pager.Prev] when there is no
+// page in the requested direction.
+var ErrNoMorePages = errors.New("no more pages")
+
+// Page is a single page of a paginated list.
+type Page[T any] struct {
+	Data []T
+
+	// Next and Prev are the links to the adjacent pages. They are empty if
+	// there is no page in that direction.
+	Next string
+	Prev string
+}
+
+// listLinks is the pagination links object shared by every list response in
+// [oapi].
+type listLinks = struct {
+	Next *string `json:"next"`
+	Prev *string `json:"prev"`
+}
+
+func newPage[T any](data []T, links listLinks) *Page[T] {
+	p := &Page[T]{Data: data}
+	if links.Next != nil {
+		p.Next = *links.Next
+	}
+	if links.Prev != nil {
+		p.Prev = *links.Prev
+	}
+	return p
+}
+
+// PageFetcher fetches the page found at link, or the first page of the list
+// if link is empty.
+type PageFetcher[T any] func(ctx context.Context, link string) (*Page[T], error)
+
+// Pager walks a paginated list one page at a time, in either direction.
+// The links to the adjacent pages are exposed by [Pager.NextURL] and
+// [Pager.PrevURL] so that a walk can be stored and later continued with
+// [Pager.Resume].
+//
+// A Pager is not safe for concurrent use.
+type Pager[T any] struct {
+	fetch   PageFetcher[T]
+	started bool
+	next    string
+	prev    string
+}
+
+// NewPager returns a Pager which fetches pages using fetch.
+// Pagers for each list endpoint are available on [Client], e.g. [Client.TransactionsPager].
+func NewPager[T any](fetch PageFetcher[T]) *Pager[T] {
+	return &Pager[T]{fetch: fetch}
+}
+
+// HasNext reports whether [Pager.Next] will fetch a page.
+func (p *Pager[T]) HasNext() bool {
+	return !p.started || p.next != ""
+}
+
+// HasPrev reports whether [Pager.Prev] will fetch a page.
+func (p *Pager[T]) HasPrev() bool {
+	return p.prev != ""
+}
+
+// NextURL returns the raw link to the page after the current one, or an
+// empty string if there is none.
+func (p *Pager[T]) NextURL() string {
+	return p.next
+}
+
+// PrevURL returns the raw link to the page before the current one, or an
+// empty string if there is none.
+func (p *Pager[T]) PrevURL() string {
+	return p.prev
+}
+
+// Resume positions the pager so that the next call to [Pager.Next] fetches
+// link, typically a value previously returned by [Pager.NextURL].
+func (p *Pager[T]) Resume(link string) {
+	p.started = true
+	p.next = link
+	p.prev = ""
+}
+
+// Next fetches the page after the current one, or the first page if no page
+// has been fetched yet.
+func (p *Pager[T]) Next(ctx context.Context) ([]T, error) {
+	if !p.HasNext() {
+		return nil, ErrNoMorePages
+	}
+	return p.load(ctx, p.next)
+}
+
+// Prev fetches the page before the current one.
+func (p *Pager[T]) Prev(ctx context.Context) ([]T, error) {
+	if !p.HasPrev() {
+		return nil, ErrNoMorePages
+	}
+	return p.load(ctx, p.prev)
+}
+
+func (p *Pager[T]) load(ctx context.Context, link string) ([]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	page, err := p.fetch(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	p.started = true
+	p.next = page.Next
+	p.prev = page.Prev
+	return page.Data, nil
+}
+
+// All returns an iterator over the records of every remaining page, fetching
+// each page as it is needed. If an error occurs it is yielded once and
+// iteration stops.
+func (p *Pager[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for p.HasNext() {
+			data, err := p.Next(ctx)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, v := range data {
+				if !yield(v, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// collect fetches the remaining pages of p until exhausted or until a limit in
+// cfg is reached.
+func collect[T any](ctx context.Context, p *Pager[T], cfg pageConfig) ([]T, error) {
+	var all []T
+	for page := 1; p.HasNext(); page++ {
+		data, err := p.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, data...)
+		if cfg.done(page, len(all)) {
+			break
+		}
+	}
+	return truncate(cfg, all), nil
+}
+
+// PageOption limits how much of a paginated list is fetched.
+type PageOption func(*pageConfig)
+
+type pageConfig struct {
+	maxPages   int
+	maxRecords int
+}
+
+// WithMaxPages stops fetching once n pages have been retrieved. A value of
+// zero or less means no limit.
+func WithMaxPages(n int) PageOption {
+	return func(p *pageConfig) {
+		p.maxPages = n
+	}
+}
+
+// WithMaxRecords stops fetching once n records have been retrieved. Any
+// records beyond n on the final page are discarded. A value of zero or less
+// means no limit.
+func WithMaxRecords(n int) PageOption {
+	return func(p *pageConfig) {
+		p.maxRecords = n
+	}
+}
+
+func newPageConfig(opts []PageOption) pageConfig {
+	var p pageConfig
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+// done reports whether either limit has been reached.
+func (p pageConfig) done(pages, records int) bool {
+	if p.maxPages > 0 && pages >= p.maxPages {
+		return true
+	}
+	if p.maxRecords > 0 && records >= p.maxRecords {
+		return true
+	}
+	return false
+}
+
+// truncate trims data to the record limit, if any.
+func truncate[T any](p pageConfig, data []T) []T {
+	if p.maxRecords > 0 && len(data) > p.maxRecords {
+		return data[:p.maxRecords]
+	}
+	return data
+}
+
+// withURL replaces the URL of a generated request with link, which is how the
+// `prev` and `next` pagination links returned by the API are followed.
+func withURL(link string) oapi.RequestEditorFn {
+	return func(ctx context.Context, req *http.Request) error {
+		u, err := req.URL.Parse(link)
+		if err != nil {
+			return err
+		}
+		req.URL = u
+		req.Host = u.Host
+		return nil
+	}
+}
+
+// linkEditors returns the request editors needed to fetch link, or none if
+// link is empty.
+func linkEditors(link string) []oapi.RequestEditorFn {
+	if link == "" {
+		return nil
+	}
+	return []oapi.RequestEditorFn{withURL(link)}
+}
+
+// AccountsPager returns a [Pager] over accounts, optionally filtered by [oapi.GetAccountsParams].
+func (c *Client) AccountsPager(params *oapi.GetAccountsParams) *Pager[oapi.AccountResource] {
+	return NewPager(func(ctx context.Context, link string) (*Page[oapi.AccountResource], error) {
+		c.logger.Debug("fetching accounts page", "link", link)
+		resp, err := c.upClient.GetAccountsWithResponse(ctx, params, linkEditors(link)...)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse("accounts", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+			return nil, err
+		}
+		return newPage(resp.JSON200.Data, resp.JSON200.Links), nil
+	})
+}
+
+// TransactionsPager returns a [Pager] over transactions for all accounts, optionally filtered by
+// [oapi.GetTransactionsParams].
+func (c *Client) TransactionsPager(params *oapi.GetTransactionsParams) *Pager[oapi.TransactionResource] {
+	return NewPager(func(ctx context.Context, link string) (*Page[oapi.TransactionResource], error) {
+		c.logger.Debug("fetching transactions page", "link", link)
+		resp, err := c.upClient.GetTransactionsWithResponse(ctx, params, linkEditors(link)...)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse("transactions", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+			return nil, err
+		}
+		return newPage(resp.JSON200.Data, resp.JSON200.Links), nil
+	})
+}
+
+// AccountTransactionsPager returns a [Pager] over transactions for a single account, optionally
+// filtered by [oapi.GetAccountsAccountIdTransactionsParams].
+func (c *Client) AccountTransactionsPager(accountID string, params *oapi.GetAccountsAccountIdTransactionsParams) *Pager[oapi.TransactionResource] {
+	return NewPager(func(ctx context.Context, link string) (*Page[oapi.TransactionResource], error) {
+		c.logger.Debug("fetching account transactions page", "accountID", accountID, "link", link)
+		resp, err := c.upClient.GetAccountsAccountIdTransactionsWithResponse(ctx, accountID, params, linkEditors(link)...)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse("account transactions", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+			return nil, err
+		}
+		return newPage(resp.JSON200.Data, resp.JSON200.Links), nil
+	})
+}
+
+// TagsPager returns a [Pager] over tags.
+func (c *Client) TagsPager(params *oapi.GetTagsParams) *Pager[oapi.TagResource] {
+	return NewPager(func(ctx context.Context, link string) (*Page[oapi.TagResource], error) {
+		c.logger.Debug("fetching tags page", "link", link)
+		resp, err := c.upClient.GetTagsWithResponse(ctx, params, linkEditors(link)...)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse("tags", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+			return nil, err
+		}
+		return newPage(resp.JSON200.Data, resp.JSON200.Links), nil
+	})
+}
+
+// WebhooksPager returns a [Pager] over webhooks.
+func (c *Client) WebhooksPager(params *oapi.GetWebhooksParams) *Pager[oapi.WebhookResource] {
+	return NewPager(func(ctx context.Context, link string) (*Page[oapi.WebhookResource], error) {
+		c.logger.Debug("fetching webhooks page", "link", link)
+		resp, err := c.upClient.GetWebhooksWithResponse(ctx, params, linkEditors(link)...)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse("webhooks", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+			return nil, err
+		}
+		return newPage(resp.JSON200.Data, resp.JSON200.Links), nil
+	})
+}
+
+// WebhookLogsPager returns a [Pager] over the delivery logs of a webhook.
+func (c *Client) WebhookLogsPager(webhookID string, params *oapi.GetWebhooksWebhookIdLogsParams) *Pager[oapi.WebhookDeliveryLogResource] {
+	return NewPager(func(ctx context.Context, link string) (*Page[oapi.WebhookDeliveryLogResource], error) {
+		c.logger.Debug("fetching webhook logs page", "webhookID", webhookID, "link", link)
+		resp, err := c.upClient.GetWebhooksWebhookIdLogsWithResponse(ctx, webhookID, params, linkEditors(link)...)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse("webhook logs", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+			return nil, err
+		}
+		return newPage(resp.JSON200.Data, resp.JSON200.Links), nil
+	})
+}
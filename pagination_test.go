@@ -0,0 +1,123 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package upgo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/upgotest"
+)
+
+const testToken = "up:yeah:test"
+
+// newTestServer starts a fake Up API serving data, returning it and a client
+// using it.
+func newTestServer(t *testing.T, data upgotest.Dataset) (*upgotest.Server, *upgo.Client) {
+	t.Helper()
+	srv := upgotest.NewServer(testToken, data)
+	t.Cleanup(srv.Close)
+	c, err := srv.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, c
+}
+
+// transactions returns n transactions on one account, where transaction
+// "t1" is the oldest.
+func transactions(n int) []oapi.TransactionResource {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ts []oapi.TransactionResource
+	for i := 1; i <= n; i++ {
+		ts = append(ts, upgotest.Transaction(fmt.Sprint("t", i), "account", "Coffee", -450, start.Add(time.Duration(i)*time.Hour)))
+	}
+	return ts
+}
+
+func ids(ts []oapi.TransactionResource) []string {
+	var ids []string
+	for _, t := range ts {
+		ids = append(ids, t.Id)
+	}
+	return ids
+}
+
+func TestPager(t *testing.T) {
+	_, c := newTestServer(t, upgotest.Dataset{Transactions: transactions(5)})
+	ctx := context.Background()
+	pageSize := 2
+	p := c.TransactionsPager(&oapi.GetTransactionsParams{PageSize: &pageSize})
+
+	step := func(name string, fetch func(context.Context) ([]oapi.TransactionResource, error), want []string, wantPrev, wantNext bool) {
+		t.Helper()
+		got, err := fetch(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !slices.Equal(ids(got), want) {
+			t.Errorf("%s: got %v, want %v", name, ids(got), want)
+		}
+		if p.HasPrev() != wantPrev || p.HasNext() != wantNext {
+			t.Errorf("%s: HasPrev() = %v, HasNext() = %v, want %v, %v", name, p.HasPrev(), p.HasNext(), wantPrev, wantNext)
+		}
+	}
+
+	if !p.HasNext() || p.HasPrev() {
+		t.Fatal("new pager cannot fetch its first page")
+	}
+	if _, err := p.Prev(ctx); !errors.Is(err, upgo.ErrNoMorePages) {
+		t.Fatalf("Prev() before first page: got %v, want %v", err, upgo.ErrNoMorePages)
+	}
+	step("first Next", p.Next, []string{"t5", "t4"}, false, true)
+	step("second Next", p.Next, []string{"t3", "t2"}, true, true)
+	step("Prev", p.Prev, []string{"t5", "t4"}, false, true)
+	step("Next after Prev", p.Next, []string{"t3", "t2"}, true, true)
+
+	// resume the walk with a new pager
+	next := p.NextURL()
+	if next == "" {
+		t.Fatal("no next URL")
+	}
+	p = c.TransactionsPager(nil)
+	p.Resume(next)
+	if !p.HasNext() || p.HasPrev() {
+		t.Fatal("resumed pager cannot fetch the next page")
+	}
+	step("Next after Resume", p.Next, []string{"t1"}, true, false)
+	if _, err := p.Next(ctx); !errors.Is(err, upgo.ErrNoMorePages) {
+		t.Errorf("Next() after last page: got %v, want %v", err, upgo.ErrNoMorePages)
+	}
+}
+
+func TestPagerAll(t *testing.T) {
+	_, c := newTestServer(t, upgotest.Dataset{Transactions: transactions(25)})
+
+	var got []oapi.TransactionResource
+	for tr, err := range c.TransactionsPager(nil).All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tr)
+	}
+	if len(got) != 25 || got[0].Id != "t25" || got[24].Id != "t1" {
+		t.Errorf("got %d transactions %v, want t25 to t1", len(got), ids(got))
+	}
+}
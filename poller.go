@@ -0,0 +1,228 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/webhook"
+)
+
+// pollerDeleteMargin is how far inside the lookback window a transaction must
+// have been created for its disappearance to be treated as a deletion, so
+// that transactions leaving the window are not mistaken for deleted ones.
+const pollerDeleteMargin = time.Hour
+
+// PollerConfig configures a [Poller]. Zero values are replaced with the
+// defaults noted on each field.
+type PollerConfig struct {
+	// Interval is the time between polls made by [Poller.Run]. It defaults
+	// to 1 minute.
+	Interval time.Duration
+
+	// Lookback is how far back each poll looks for transactions. Changes to
+	// older transactions, such as a held transaction settling, are missed.
+	// It defaults to 7 days.
+	Lookback time.Duration
+
+	// EmitExisting raises TRANSACTION_CREATED events for the transactions
+	// found by the first poll. Otherwise the first poll only records them.
+	EmitExisting bool
+}
+
+// Poller raises webhook events by repeatedly fetching recent transactions
+// and comparing them with those previously seen, for use where Up cannot
+// reach a webhook URL. Events are passed to a [webhook.Dispatcher], so the
+// same handlers serve both webhooks and polling.
+//
+// The events raised are TRANSACTION_CREATED, TRANSACTION_SETTLED,
+// TRANSACTION_DELETED and [webhook.TransactionAmountChanged]. Each event has
+// [webhook.Event.Transaction] set to the transaction as now fetched, and
+// [webhook.Event.Previous] set to the transaction as last seen. Event IDs are
+// derived from the change, so a dispatcher using [webhook.WithDedup] ignores
+// events raised again.
+//
+// If a handler returns an error the transaction's state is not updated, so
+// the event is raised again by the next poll.
+type Poller struct {
+	client     *Client
+	dispatcher *webhook.Dispatcher
+	cfg        PollerConfig
+
+	mu      sync.Mutex
+	started bool
+	seen    map[string]oapi.TransactionResource
+}
+
+// NewPoller returns a Poller passing events to d.
+func (c *Client) NewPoller(d *webhook.Dispatcher, cfg PollerConfig) *Poller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.Lookback <= 0 {
+		cfg.Lookback = 7 * 24 * time.Hour
+	}
+	return &Poller{
+		client:     c,
+		dispatcher: d,
+		cfg:        cfg,
+		seen:       make(map[string]oapi.TransactionResource),
+	}
+}
+
+// Run polls immediately and then at each interval until ctx is done,
+// returning the context's error. Errors from individual polls are logged and
+// do not stop the poller.
+func (p *Poller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Poll(ctx); err != nil && ctx.Err() == nil {
+			p.client.logger.Error("transaction poll failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches recent transactions once, dispatching an event for each
+// change since the previous poll. Errors returned by handlers are joined
+// together and returned once every change has been dispatched.
+func (p *Poller) Poll(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	since := time.Now().Add(-p.cfg.Lookback)
+	pageSize := 100
+	trans, err := p.client.GetTransactions(ctx, &oapi.GetTransactionsParams{FilterSince: &since, PageSize: &pageSize})
+	if err != nil {
+		return err
+	}
+
+	baseline := !p.started && !p.cfg.EmitExisting
+	p.started = true
+
+	var errs []error
+	events := 0
+	fetched := make(map[string]bool, len(trans))
+
+	// transactions are listed newest first, so raise events oldest first
+	for _, t := range slices.Backward(trans) {
+		fetched[t.Id] = true
+		if baseline {
+			p.seen[t.Id] = t
+			continue
+		}
+
+		var prev *oapi.TransactionResource
+		if seen, ok := p.seen[t.Id]; ok {
+			prev = &seen
+		}
+		if err := p.dispatchChanges(ctx, prev, &t, &events); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.seen[t.Id] = t
+	}
+
+	cutoff := since.Add(pollerDeleteMargin)
+	for id, prev := range p.seen {
+		if fetched[id] {
+			continue
+		}
+		if prev.Attributes.CreatedAt.Before(cutoff) {
+			// left the window rather than deleted
+			delete(p.seen, id)
+			continue
+		}
+		if err := p.dispatch(ctx, oapi.TRANSACTIONDELETED, pollerEventID(oapi.TRANSACTIONDELETED, id), &prev, nil, &events); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(p.seen, id)
+	}
+
+	p.client.logger.Debug("polled transactions", "transactions", len(trans), "events", events, "baseline", baseline)
+	return errors.Join(errs...)
+}
+
+// dispatchChanges dispatches an event for each change from prev to t. prev is
+// nil if t has not been seen before.
+func (p *Poller) dispatchChanges(ctx context.Context, prev, t *oapi.TransactionResource, events *int) error {
+	if prev == nil {
+		return p.dispatch(ctx, oapi.TRANSACTIONCREATED, pollerEventID(oapi.TRANSACTIONCREATED, t.Id), nil, t, events)
+	}
+
+	if prev.Attributes.Status != oapi.SETTLED && t.Attributes.Status == oapi.SETTLED {
+		if err := p.dispatch(ctx, oapi.TRANSACTIONSETTLED, pollerEventID(oapi.TRANSACTIONSETTLED, t.Id), prev, t, events); err != nil {
+			return err
+		}
+	}
+
+	if amount := t.Attributes.Amount.ValueInBaseUnits; amount != prev.Attributes.Amount.ValueInBaseUnits {
+		id := fmt.Sprintf("%s:%d", pollerEventID(webhook.TransactionAmountChanged, t.Id), amount)
+		if err := p.dispatch(ctx, webhook.TransactionAmountChanged, id, prev, t, events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch builds an event of the given type and passes it to the dispatcher.
+func (p *Poller) dispatch(ctx context.Context, eventType oapi.WebhookEventTypeEnum, id string, prev, t *oapi.TransactionResource, events *int) error {
+	transactionID := ""
+	if t != nil {
+		transactionID = t.Id
+	} else if prev != nil {
+		transactionID = prev.Id
+	}
+
+	var res oapi.WebhookEventResource
+	res.Type = "webhook-events"
+	res.Id = id
+	res.Attributes.EventType = eventType
+	res.Attributes.CreatedAt = time.Now()
+	res.Relationships.Transaction = &struct {
+		Data struct {
+			Id   string `json:"id"`
+			Type string `json:"type"`
+		} `json:"data"`
+		Links *struct {
+			Related string `json:"related"`
+		} `json:"links,omitempty"`
+	}{}
+	res.Relationships.Transaction.Data.Id = transactionID
+	res.Relationships.Transaction.Data.Type = "transactions"
+
+	*events++
+	return p.dispatcher.Dispatch(ctx, &webhook.Event{WebhookEventResource: res, Transaction: t, Previous: prev})
+}
+
+// pollerEventID returns the ID of the event of type eventType raised for the
+// transaction identified by transactionID.
+func pollerEventID(eventType oapi.WebhookEventTypeEnum, transactionID string) string {
+	return "poll:" + string(eventType) + ":" + transactionID
+}
@@ -0,0 +1,189 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/upgotest"
+	"github.com/porjo/upgo/webhook"
+)
+
+// pollEvents records the events handled by a dispatcher, unless fail returns
+// an error for them.
+type pollEvents struct {
+	events []*webhook.Event
+	fail   func(ev *webhook.Event) error
+}
+
+func (p *pollEvents) dispatcher() *webhook.Dispatcher {
+	d := webhook.NewDispatcher()
+	for _, typ := range []oapi.WebhookEventTypeEnum{oapi.TRANSACTIONCREATED, oapi.TRANSACTIONSETTLED, oapi.TRANSACTIONDELETED, webhook.TransactionAmountChanged} {
+		d.Handle(typ, func(ctx context.Context, ev *webhook.Event) error {
+			if p.fail != nil {
+				if err := p.fail(ev); err != nil {
+					return err
+				}
+			}
+			p.events = append(p.events, ev)
+			return nil
+		})
+	}
+	return d
+}
+
+// take returns the events handled since it was last called, as "TYPE id".
+func (p *pollEvents) take() []string {
+	var got []string
+	for _, ev := range p.events {
+		got = append(got, string(ev.Type())+" "+ev.TransactionID())
+	}
+	p.events = nil
+	return got
+}
+
+func heldTransaction(id string, amount int64, createdAt time.Time) oapi.TransactionResource {
+	t := upgotest.Transaction(id, "account", "Coffee", amount, createdAt)
+	t.Attributes.Status = oapi.HELD
+	t.Attributes.SettledAt = nil
+	return t
+}
+
+func TestPoller(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	srv, c := newTestServer(t, upgotest.Dataset{Transactions: []oapi.TransactionResource{
+		heldTransaction("held", -450, now.Add(-2*time.Hour)),
+		upgotest.Transaction("settled", "account", "Groceries", -8000, now.Add(-3*time.Hour)),
+		// inside the window, but too close to its start for a disappearance to
+		// be taken as a deletion
+		upgotest.Transaction("old", "account", "Rent", -50000, now.Add(-23*time.Hour-30*time.Minute)),
+	}})
+	ctx := context.Background()
+
+	events := &pollEvents{}
+	p := c.NewPoller(events.dispatcher(), upgo.PollerConfig{Lookback: 24 * time.Hour})
+	poll := func(name string, want ...string) {
+		t.Helper()
+		if err := p.Poll(ctx); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := events.take(); !slices.Equal(got, want) {
+			t.Errorf("%s: got events %q, want %q", name, got, want)
+		}
+	}
+
+	poll("first poll")
+
+	srv.AddTransaction(heldTransaction("new", -1200, now.Add(-time.Hour)))
+	srv.UpdateTransaction("held", func(t *oapi.TransactionResource) {
+		t.Attributes.Status = oapi.SETTLED
+		t.Attributes.SettledAt = &now
+		t.Attributes.Amount = upgotest.Money(-500)
+	})
+	srv.UpdateTransaction("settled", func(t *oapi.TransactionResource) {
+		t.Attributes.Amount = upgotest.Money(-7500)
+	})
+	if err := p.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := events.events
+	if names := events.take(); !slices.Equal(names, []string{
+		"TRANSACTION_AMOUNT_CHANGED settled",
+		"TRANSACTION_SETTLED held",
+		"TRANSACTION_AMOUNT_CHANGED held",
+		"TRANSACTION_CREATED new",
+	}) {
+		t.Fatalf("changes: got events %q", names)
+	}
+	settled := got[1]
+	if settled.Previous == nil || settled.Previous.Attributes.Status != oapi.HELD ||
+		settled.Transaction == nil || settled.Transaction.Attributes.Status != oapi.SETTLED {
+		t.Errorf("settled event has previous %v and transaction %v", settled.Previous, settled.Transaction)
+	}
+	amount := got[0]
+	if amount.Previous.Attributes.Amount.ValueInBaseUnits != -8000 || amount.Transaction.Attributes.Amount.ValueInBaseUnits != -7500 {
+		t.Errorf("amount changed event from %d to %d, want -8000 to -7500",
+			amount.Previous.Attributes.Amount.ValueInBaseUnits, amount.Transaction.Attributes.Amount.ValueInBaseUnits)
+	}
+	if created := got[3]; created.Previous != nil || created.Transaction == nil || created.Transaction.Id != "new" {
+		t.Errorf("created event has previous %v and transaction %v", created.Previous, created.Transaction)
+	}
+
+	poll("no changes")
+
+	srv.DeleteTransaction("new")
+	srv.DeleteTransaction("old")
+	poll("deletions", "TRANSACTION_DELETED new")
+	poll("after deletions")
+}
+
+func TestPollerEmitExisting(t *testing.T) {
+	_, c := newTestServer(t, upgotest.Dataset{Transactions: transactions(2)})
+
+	events := &pollEvents{}
+	p := c.NewPoller(events.dispatcher(), upgo.PollerConfig{Lookback: 100 * 365 * 24 * time.Hour, EmitExisting: true})
+	if err := p.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events.take(), []string{"TRANSACTION_CREATED t1", "TRANSACTION_CREATED t2"}; !slices.Equal(got, want) {
+		t.Errorf("got events %q, want %q", got, want)
+	}
+}
+
+func TestPollerHandlerError(t *testing.T) {
+	srv, c := newTestServer(t, upgotest.Dataset{})
+	ctx := context.Background()
+
+	failures := 1
+	events := &pollEvents{fail: func(ev *webhook.Event) error {
+		if failures > 0 {
+			failures--
+			return errors.New("handler failed")
+		}
+		return nil
+	}}
+	p := c.NewPoller(events.dispatcher(), upgo.PollerConfig{})
+	if err := p.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.AddTransaction(upgotest.Transaction("new", "account", "Coffee", -450, time.Now().Add(-time.Minute)))
+	if err := p.Poll(ctx); err == nil {
+		t.Fatal("handler error not returned")
+	}
+	if got := events.take(); len(got) != 0 {
+		t.Errorf("got events %q, want none handled", got)
+	}
+
+	if err := p.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := events.take(), []string{"TRANSACTION_CREATED new"}; !slices.Equal(got, want) {
+		t.Errorf("after failure: got events %q, want %q", got, want)
+	}
+
+	if err := p.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := events.take(); len(got) != 0 {
+		t.Errorf("after success: got events %q, want none", got)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	b := newTokenBucket(20, 3) // a token every 50ms
+
+	start := time.Now()
+	for range 3 {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("burst took %v, want no wait", elapsed)
+	}
+
+	prev := time.Now()
+	for i := range 3 {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		if gap := now.Sub(prev); gap < 35*time.Millisecond || gap > 150*time.Millisecond {
+			t.Errorf("request %d after burst waited %v, want about 50ms", i+1, gap)
+		}
+		prev = now
+	}
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+	b := newTokenBucket(10, 1) // a token every 100ms
+
+	start := time.Now()
+	if err := b.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// the cancelled waiter handed its token back, so the next waits for the
+	// first token to be replaced rather than a second
+	if err := b.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond || elapsed > 170*time.Millisecond {
+		t.Errorf("next token after cancel took %v, want about 100ms", elapsed)
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	b := newTokenBucket(0, 1)
+	start := time.Now()
+	for range 100 {
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("unlimited bucket took %v, want no wait", elapsed)
+	}
+}
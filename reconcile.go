@@ -0,0 +1,244 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// WebhookSpec describes a webhook which should exist.
+type WebhookSpec struct {
+	URL         string
+	Description string
+}
+
+// ReconcileOptions controls how [Client.PlanWebhooks] plans changes.
+type ReconcileOptions struct {
+	// Prune deletes existing webhooks whose URL is not desired. Otherwise
+	// they are left alone.
+	Prune bool
+
+	// Replace deletes and recreates existing webhooks whose description
+	// differs from that desired, as the API cannot update a webhook.
+	// Otherwise differing descriptions are ignored. Replacing a webhook
+	// issues a new secret key.
+	Replace bool
+}
+
+// SecretSink receives the webhooks created when applying a plan, so that
+// their secret keys can be stored. If it returns an error the webhook is
+// deleted again, as its secret key would otherwise be lost.
+type SecretSink func(ctx context.Context, created *CreatedWebhook) error
+
+// WebhookReplacement is an existing webhook which is to be recreated.
+type WebhookReplacement struct {
+	Existing oapi.WebhookResource
+	Desired  WebhookSpec
+}
+
+// WebhookPlan is the set of changes needed to make the existing webhooks
+// match those desired. It is returned by [Client.PlanWebhooks] and carried out
+// by [Client.ApplyWebhookPlan].
+type WebhookPlan struct {
+	Create  []WebhookSpec
+	Replace []WebhookReplacement
+	Delete  []oapi.WebhookResource
+
+	// Unchanged holds the existing webhooks which are left as they are,
+	// including those which are not desired or share a desired URL when
+	// not pruning.
+	Unchanged []oapi.WebhookResource
+}
+
+// Empty reports whether the plan makes no changes.
+func (p *WebhookPlan) Empty() bool {
+	return len(p.Create) == 0 && len(p.Replace) == 0 && len(p.Delete) == 0
+}
+
+// Render writes the plan to w, one change per line.
+func (p *WebhookPlan) Render(w io.Writer) error {
+	var lines []string
+	for _, s := range p.Create {
+		lines = append(lines, fmt.Sprintf("+ create %s %q", s.URL, s.Description))
+	}
+	for _, r := range p.Replace {
+		lines = append(lines, fmt.Sprintf("~ replace %s %s %q -> %q", r.Existing.Id, r.Desired.URL, webhookDescription(r.Existing), r.Desired.Description))
+	}
+	for _, wh := range p.Delete {
+		lines = append(lines, fmt.Sprintf("- delete %s %s %q", wh.Id, wh.Attributes.Url, webhookDescription(wh)))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no changes")
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the plan as rendered by [WebhookPlan.Render].
+func (p *WebhookPlan) String() string {
+	var b strings.Builder
+	_ = p.Render(&b)
+	return b.String()
+}
+
+// PlanWebhooks compares desired against the existing webhooks, returning the
+// changes needed to make them match. Webhooks are matched by URL. Nothing is
+// changed until the plan is passed to [Client.ApplyWebhookPlan].
+//
+// An error matching [ErrInvalidWebhook] is returned if a desired webhook is
+// invalid or its URL is given more than once.
+func (c *Client) PlanWebhooks(ctx context.Context, desired []WebhookSpec, opts ReconcileOptions) (*WebhookPlan, error) {
+	c.logger.Info("PlanWebhooks", "desired", len(desired), "prune", opts.Prune, "replace", opts.Replace)
+
+	seen := make(map[string]bool, len(desired))
+	for _, s := range desired {
+		if err := validateWebhook(s.URL, s.Description); err != nil {
+			return nil, err
+		}
+		if seen[s.URL] {
+			return nil, fmt.Errorf("%w: URL %q is desired more than once", ErrInvalidWebhook, s.URL)
+		}
+		seen[s.URL] = true
+	}
+
+	existing, err := c.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byURL := make(map[string][]oapi.WebhookResource)
+	for _, wh := range existing {
+		byURL[wh.Attributes.Url] = append(byURL[wh.Attributes.Url], wh)
+	}
+
+	plan := &WebhookPlan{}
+	for _, s := range desired {
+		matches := byURL[s.URL]
+		delete(byURL, s.URL)
+		if len(matches) == 0 {
+			plan.Create = append(plan.Create, s)
+			continue
+		}
+
+		// keep the webhook which best matches, treating any others with the
+		// same URL as stale
+		keep := 0
+		for i, wh := range matches {
+			if webhookDescription(wh) == s.Description {
+				keep = i
+				break
+			}
+		}
+		for i, wh := range matches {
+			switch {
+			case i != keep && opts.Prune:
+				plan.Delete = append(plan.Delete, wh)
+			case i != keep:
+				plan.Unchanged = append(plan.Unchanged, wh)
+			case webhookDescription(wh) != s.Description && opts.Replace:
+				plan.Replace = append(plan.Replace, WebhookReplacement{Existing: wh, Desired: s})
+			default:
+				plan.Unchanged = append(plan.Unchanged, wh)
+			}
+		}
+	}
+	// preserve the order in which the API listed the remaining webhooks
+	for _, wh := range existing {
+		if _, ok := byURL[wh.Attributes.Url]; !ok {
+			continue
+		}
+		if opts.Prune {
+			plan.Delete = append(plan.Delete, wh)
+		} else {
+			plan.Unchanged = append(plan.Unchanged, wh)
+		}
+	}
+	return plan, nil
+}
+
+// ApplyWebhookPlan carries out plan, passing each created webhook to sink.
+// Webhooks are created before any are deleted, so that replaced webhooks
+// keep receiving events until their replacement exists. Applying stops at the
+// first error, which is returned.
+func (c *Client) ApplyWebhookPlan(ctx context.Context, plan *WebhookPlan, sink SecretSink) error {
+	c.logger.Info("ApplyWebhookPlan", "create", len(plan.Create), "replace", len(plan.Replace), "delete", len(plan.Delete))
+	if sink == nil && (len(plan.Create) > 0 || len(plan.Replace) > 0) {
+		return errors.New("a secret sink is required to create webhooks")
+	}
+
+	for _, s := range plan.Create {
+		if err := c.createWebhook(ctx, s, sink); err != nil {
+			return err
+		}
+	}
+	for _, r := range plan.Replace {
+		if err := c.createWebhook(ctx, r.Desired, sink); err != nil {
+			return err
+		}
+		if err := c.DeleteWebhook(ctx, r.Existing.Id); err != nil {
+			return err
+		}
+	}
+	for _, wh := range plan.Delete {
+		if err := c.DeleteWebhook(ctx, wh.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconcileWebhooks plans and applies the changes needed to make the existing
+// webhooks match those desired, as for [Client.PlanWebhooks] and
+// [Client.ApplyWebhookPlan]. The applied plan is returned.
+func (c *Client) ReconcileWebhooks(ctx context.Context, desired []WebhookSpec, opts ReconcileOptions, sink SecretSink) (*WebhookPlan, error) {
+	plan, err := c.PlanWebhooks(ctx, desired, opts)
+	if err != nil {
+		return nil, err
+	}
+	return plan, c.ApplyWebhookPlan(ctx, plan, sink)
+}
+
+// createWebhook creates the webhook described by s and passes it to sink,
+// deleting it again if the sink fails.
+func (c *Client) createWebhook(ctx context.Context, s WebhookSpec, sink SecretSink) error {
+	created, err := c.CreateWebhook(ctx, s.URL, s.Description)
+	if err != nil {
+		return err
+	}
+	if err := sink(ctx, created); err != nil {
+		c.logger.Warn("secret sink failed, deleting webhook", "id", created.Webhook.Id, "err", err)
+		if delErr := c.DeleteWebhook(context.WithoutCancel(ctx), created.Webhook.Id); delErr != nil {
+			err = errors.Join(err, delErr)
+		}
+		return fmt.Errorf("error storing secret key of webhook %s: %w", created.Webhook.Id, err)
+	}
+	return nil
+}
+
+func webhookDescription(wh oapi.WebhookResource) string {
+	if wh.Attributes.Description == nil {
+		return ""
+	}
+	return *wh.Attributes.Description
+}
@@ -0,0 +1,226 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/upgotest"
+)
+
+func reconcileDataset() upgotest.Dataset {
+	return upgotest.Dataset{Webhooks: []oapi.WebhookResource{
+		upgotest.Webhook("a", "https://a.example.com", "A"),
+		upgotest.Webhook("b1", "https://b.example.com", "B"),
+		upgotest.Webhook("b2", "https://b.example.com", "B old"),
+		upgotest.Webhook("c", "https://c.example.com", "C old"),
+		upgotest.Webhook("x", "https://x.example.com", "X"),
+	}}
+}
+
+var reconcileDesired = []upgo.WebhookSpec{
+	{URL: "https://a.example.com", Description: "A"},
+	{URL: "https://b.example.com", Description: "B"},
+	{URL: "https://c.example.com", Description: "C"},
+	{URL: "https://d.example.com", Description: "D"},
+}
+
+func webhookIDs(whs []oapi.WebhookResource) []string {
+	var ids []string
+	for _, wh := range whs {
+		ids = append(ids, wh.Id)
+	}
+	return ids
+}
+
+// webhookURLs returns the URL and description of each webhook.
+func webhookURLs(whs []oapi.WebhookResource) []string {
+	var urls []string
+	for _, wh := range whs {
+		desc := ""
+		if wh.Attributes.Description != nil {
+			desc = *wh.Attributes.Description
+		}
+		urls = append(urls, wh.Attributes.Url+" "+desc)
+	}
+	slices.Sort(urls)
+	return urls
+}
+
+func TestReconcileWebhooks(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          upgo.ReconcileOptions
+		wantCreate    []string
+		wantReplace   []string
+		wantDelete    []string
+		wantUnchanged []string
+		wantAfter     []string
+	}{
+		{
+			name:          "create only",
+			wantCreate:    []string{"https://d.example.com"},
+			wantUnchanged: []string{"a", "b1", "b2", "c", "x"},
+			wantAfter: []string{
+				"https://a.example.com A", "https://b.example.com B", "https://b.example.com B old",
+				"https://c.example.com C old", "https://d.example.com D", "https://x.example.com X",
+			},
+		},
+		{
+			name:          "prune",
+			opts:          upgo.ReconcileOptions{Prune: true},
+			wantCreate:    []string{"https://d.example.com"},
+			wantDelete:    []string{"b2", "x"},
+			wantUnchanged: []string{"a", "b1", "c"},
+			wantAfter: []string{
+				"https://a.example.com A", "https://b.example.com B", "https://c.example.com C old", "https://d.example.com D",
+			},
+		},
+		{
+			name:          "replace",
+			opts:          upgo.ReconcileOptions{Replace: true},
+			wantCreate:    []string{"https://d.example.com"},
+			wantReplace:   []string{"c"},
+			wantUnchanged: []string{"a", "b1", "b2", "x"},
+			wantAfter: []string{
+				"https://a.example.com A", "https://b.example.com B", "https://b.example.com B old",
+				"https://c.example.com C", "https://d.example.com D", "https://x.example.com X",
+			},
+		},
+		{
+			name:          "prune and replace",
+			opts:          upgo.ReconcileOptions{Prune: true, Replace: true},
+			wantCreate:    []string{"https://d.example.com"},
+			wantReplace:   []string{"c"},
+			wantDelete:    []string{"b2", "x"},
+			wantUnchanged: []string{"a", "b1"},
+			wantAfter: []string{
+				"https://a.example.com A", "https://b.example.com B", "https://c.example.com C", "https://d.example.com D",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, c := newTestServer(t, reconcileDataset())
+			ctx := context.Background()
+
+			plan, err := c.PlanWebhooks(ctx, reconcileDesired, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var create, replace []string
+			for _, s := range plan.Create {
+				create = append(create, s.URL)
+			}
+			for _, r := range plan.Replace {
+				replace = append(replace, r.Existing.Id)
+			}
+			if !slices.Equal(create, tt.wantCreate) {
+				t.Errorf("Create = %v, want %v", create, tt.wantCreate)
+			}
+			if !slices.Equal(replace, tt.wantReplace) {
+				t.Errorf("Replace = %v, want %v", replace, tt.wantReplace)
+			}
+			if got := webhookIDs(plan.Delete); !slices.Equal(got, tt.wantDelete) {
+				t.Errorf("Delete = %v, want %v", got, tt.wantDelete)
+			}
+			if got := webhookIDs(plan.Unchanged); !slices.Equal(got, tt.wantUnchanged) {
+				t.Errorf("Unchanged = %v, want %v", got, tt.wantUnchanged)
+			}
+
+			var secrets []string
+			sink := func(ctx context.Context, created *upgo.CreatedWebhook) error {
+				secrets = append(secrets, created.SecretKey)
+				return nil
+			}
+			if err := c.ApplyWebhookPlan(ctx, plan, sink); err != nil {
+				t.Fatal(err)
+			}
+			if want := len(tt.wantCreate) + len(tt.wantReplace); len(secrets) != want || slices.Contains(secrets, "") {
+				t.Errorf("sink got secret keys %q, want %d", secrets, want)
+			}
+			if got := webhookURLs(srv.Webhooks()); !slices.Equal(got, tt.wantAfter) {
+				t.Errorf("webhooks after applying = %q, want %q", got, tt.wantAfter)
+			}
+
+			// applying again changes nothing more
+			plan, err = c.PlanWebhooks(ctx, reconcileDesired, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !plan.Empty() {
+				t.Errorf("plan after applying is not empty:\n%s", plan)
+			}
+		})
+	}
+}
+
+func TestPlanWebhooksInvalid(t *testing.T) {
+	_, c := newTestServer(t, reconcileDataset())
+
+	desired := []upgo.WebhookSpec{{URL: "https://a.example.com"}, {URL: "https://a.example.com"}}
+	if _, err := c.PlanWebhooks(context.Background(), desired, upgo.ReconcileOptions{}); !errors.Is(err, upgo.ErrInvalidWebhook) {
+		t.Errorf("got error %v, want %v", err, upgo.ErrInvalidWebhook)
+	}
+}
+
+func TestApplyWebhookPlanSinkFails(t *testing.T) {
+	srv, c := newTestServer(t, reconcileDataset())
+	ctx := context.Background()
+	before := webhookURLs(srv.Webhooks())
+
+	plan := &upgo.WebhookPlan{Create: []upgo.WebhookSpec{{URL: "https://d.example.com", Description: "D"}}}
+	sinkErr := errors.New("sink failed")
+	err := c.ApplyWebhookPlan(ctx, plan, func(ctx context.Context, created *upgo.CreatedWebhook) error {
+		return sinkErr
+	})
+	if !errors.Is(err, sinkErr) {
+		t.Errorf("got error %v, want %v", err, sinkErr)
+	}
+	if got := webhookURLs(srv.Webhooks()); !slices.Equal(got, before) {
+		t.Errorf("webhooks = %q, want created webhook deleted", got)
+	}
+}
+
+func TestApplyWebhookPlanNilSink(t *testing.T) {
+	srv, c := newTestServer(t, reconcileDataset())
+	ctx := context.Background()
+	before := webhookURLs(srv.Webhooks())
+
+	plan, err := c.PlanWebhooks(ctx, reconcileDesired, upgo.ReconcileOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ApplyWebhookPlan(ctx, plan, nil); err == nil {
+		t.Error("plan creating webhooks applied without a sink")
+	}
+	if got := webhookURLs(srv.Webhooks()); !slices.Equal(got, before) {
+		t.Errorf("webhooks = %q, want unchanged", got)
+	}
+
+	// a plan which only deletes needs no sink
+	plan.Create = nil
+	if err := c.ApplyWebhookPlan(ctx, plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := webhookIDs(srv.Webhooks()); !slices.Equal(got, []string{"a", "b1", "c"}) {
+		t.Errorf("webhooks = %v, want [a b1 c]", got)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests are retried after a transient failure,
+// being a network error, an HTTP 429 or an HTTP 5xx response.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request,
+	// including the first.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry. The delay doubles for
+	// each subsequent retry, up to MaxBackoff, and is randomly jittered.
+	// A Retry-After header sent by the API takes precedence, but is also
+	// limited to MaxBackoff if that is set.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// RetryMutating allows requests which modify data (POST, PATCH, DELETE)
+	// to be retried. By default only GET, HEAD and OPTIONS requests are.
+	RetryMutating bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for use with [WithRetryPolicy].
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+}
+
+// WithRetryPolicy enables retrying of requests according to policy.
+// Requests are not retried otherwise.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = &policy
+	}
+}
+
+// retryTransport is an [http.RoundTripper] which retries requests according to
+// a [RetryPolicy].
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+	logger *slog.Logger
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.retryable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		// a request without GetBody has no body or http.NoBody, which can be
+		// sent again as is
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(r)
+		if attempt >= t.policy.MaxAttempts || !shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp); ok {
+				delay = d
+				if t.policy.MaxBackoff > 0 {
+					delay = min(delay, t.policy.MaxBackoff)
+				}
+			}
+			// drain the body so that the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		if err != nil {
+			t.logger.Warn("retrying request", "method", req.Method, "url", req.URL.String(), "attempt", attempt, "delay", delay, "err", err)
+		} else {
+			t.logger.Warn("retrying request", "method", req.Method, "url", req.URL.String(), "attempt", attempt, "delay", delay, "status", resp.StatusCode)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether the policy permits req to be retried.
+func (t *retryTransport) retryable(req *http.Request) bool {
+	if t.policy.MaxAttempts <= 1 {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// body cannot be replayed
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return t.policy.RetryMutating
+}
+
+// backoff returns the jittered delay before the retry following attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.policy.MinBackoff
+	for i := 1; i < attempt && d < t.policy.MaxBackoff; i++ {
+		d *= 2
+	}
+	if t.policy.MaxBackoff > 0 && d > t.policy.MaxBackoff {
+		d = t.policy.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// equal jitter: somewhere between half and all of d
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter parses the Retry-After header of resp, which may be either a
+// number of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,186 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	rt := &retryTransport{policy: RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}}
+
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{20, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if d := rt.backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		got, ok := retryAfter(resp)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}}
+	if got, ok := retryAfter(resp); !ok || got <= 0 || got > time.Minute {
+		t.Errorf("retryAfter(date in a minute) = %v, %v, want up to a minute", got, ok)
+	}
+}
+
+// retryServer responds to each request with the next of statuses, repeating
+// the last, recording the bodies it receives.
+type retryServer struct {
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	bodies   []string
+}
+
+func (s *retryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.statuses[min(len(s.bodies), len(s.statuses)-1)]
+	s.bodies = append(s.bodies, string(body))
+	for k, v := range s.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		body          io.Reader
+		retryMutating bool
+		statuses      []int
+		wantRequests  int
+		wantStatus    int
+	}{
+		{"GET retried until success", http.MethodGet, nil, false, []int{503, 502, 200}, 3, 200},
+		{"GET rate limited", http.MethodGet, nil, false, []int{429, 200}, 2, 200},
+		{"GET client error not retried", http.MethodGet, nil, false, []int{400}, 1, 400},
+		{"GET attempts exhausted", http.MethodGet, nil, false, []int{500}, 4, 500},
+		{"GET with NoBody", http.MethodGet, http.NoBody, false, []int{503, 200}, 2, 200},
+		{"POST not retried", http.MethodPost, strings.NewReader("body"), false, []int{503, 200}, 1, 503},
+		{"PATCH not retried", http.MethodPatch, strings.NewReader("body"), false, []int{503, 200}, 1, 503},
+		{"DELETE not retried", http.MethodDelete, nil, false, []int{503, 204}, 1, 503},
+		{"POST retried when mutating allowed", http.MethodPost, strings.NewReader("body"), true, []int{503, 201}, 2, 201},
+		{"POST with NoBody retried when mutating allowed", http.MethodPost, http.NoBody, true, []int{503, 201}, 2, 201},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &retryServer{statuses: tt.statuses}
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+
+			client := &http.Client{Transport: &retryTransport{
+				next: http.DefaultTransport,
+				policy: RetryPolicy{
+					MaxAttempts:   4,
+					MinBackoff:    time.Millisecond,
+					MaxBackoff:    5 * time.Millisecond,
+					RetryMutating: tt.retryMutating,
+				},
+				logger: slog.New(slog.DiscardHandler),
+			}}
+			req, err := http.NewRequest(tt.method, srv.URL, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if len(s.bodies) != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", len(s.bodies), tt.wantRequests)
+			}
+			for i, b := range s.bodies {
+				if b != s.bodies[0] {
+					t.Errorf("request %d had body %q, want %q", i+1, b, s.bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfterLimitedToMaxBackoff(t *testing.T) {
+	s := &retryServer{statuses: []int{429, 200}, header: http.Header{"Retry-After": {"3600"}}}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &retryTransport{
+		next:   http.DefaultTransport,
+		policy: RetryPolicy{MaxAttempts: 2, MaxBackoff: 10 * time.Millisecond},
+		logger: slog.New(slog.DiscardHandler),
+	}}
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retry took %v, want Retry-After limited to MaxBackoff", elapsed)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// GetTags returns all tags in use. Pagination behaves as for [Client.GetTransactions].
+func (c *Client) GetTags(ctx context.Context, params *oapi.GetTagsParams, opts ...PageOption) ([]oapi.TagResource, error) {
+	c.logger.Info("GetTags")
+	return collect(ctx, c.TagsPager(params), newPageConfig(opts))
+}
+
+// RetagOptions controls how [Client.RenameTag] and [Client.MergeTags] apply changes.
+type RetagOptions struct {
+	// DryRun finds the transactions which would be changed without changing them.
+	DryRun bool
+
+	// Concurrency is the number of transactions updated at once, as for [BulkUpdate].
+	Concurrency int
+}
+
+// TagChange describes the transactions moved from one tag to another.
+type TagChange struct {
+	From string
+	To   string
+
+	// TransactionIDs identifies every transaction which carried From.
+	TransactionIDs []string
+
+	// Report is the outcome of updating the transactions. It is nil for a dry run.
+	Report *BulkReport
+}
+
+// RenameTag replaces the tag labelled from with one labelled to on every
+// transaction carrying it. The API has no rename operation, so each
+// transaction has the new tag added and the old tag removed. Once no
+// transactions carry it, the old tag no longer exists.
+//
+// Failures of individual transactions are recorded in the report of the
+// returned change.
+func (c *Client) RenameTag(ctx context.Context, from, to string, opts RetagOptions) (*TagChange, error) {
+	c.logger.Info("RenameTag", "from", from, "to", to, "dryRun", opts.DryRun)
+	return c.retag(ctx, from, to, opts)
+}
+
+// MergeTags replaces each of the tags labelled from with the tag labelled to,
+// as for [Client.RenameTag]. A change is returned for each tag in from, up to
+// and including any that failed.
+func (c *Client) MergeTags(ctx context.Context, from []string, to string, opts RetagOptions) ([]TagChange, error) {
+	c.logger.Info("MergeTags", "from", from, "to", to, "dryRun", opts.DryRun)
+	var changes []TagChange
+	for _, tag := range from {
+		if tag == to {
+			continue
+		}
+		change, err := c.retag(ctx, tag, to, opts)
+		if change != nil {
+			changes = append(changes, *change)
+		}
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+func (c *Client) retag(ctx context.Context, from, to string, opts RetagOptions) (*TagChange, error) {
+	if from == "" || to == "" {
+		return nil, errors.New("tag labels must not be empty")
+	}
+	if from == to {
+		return nil, fmt.Errorf("cannot move tag %q to itself", from)
+	}
+
+	// find every transaction first, as removing the tag while paging through
+	// transactions filtered by it would shift the pages
+	trans, err := c.GetTransactions(ctx, &oapi.GetTransactionsParams{FilterTag: &from})
+	if err != nil {
+		return nil, err
+	}
+
+	change := &TagChange{
+		From:           from,
+		To:             to,
+		TransactionIDs: make([]string, 0, len(trans)),
+	}
+	for _, t := range trans {
+		change.TransactionIDs = append(change.TransactionIDs, t.Id)
+	}
+
+	if opts.DryRun {
+		for _, id := range change.TransactionIDs {
+			c.logger.Info("dry run: would move tag", "from", from, "to", to, "transactionID", id)
+		}
+		return change, nil
+	}
+	if len(change.TransactionIDs) == 0 {
+		change.Report = &BulkReport{}
+		return change, nil
+	}
+
+	change.Report, err = c.BulkUpdate(ctx, change.TransactionIDs, BulkUpdate{
+		AddTags:     []string{to},
+		RemoveTags:  []string{from},
+		Concurrency: opts.Concurrency,
+	})
+	return change, err
+}
@@ -0,0 +1,168 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo_test
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/upgotest"
+)
+
+// tagDataset returns 15 transactions, t1 to t12 tagged "cofee", t13 and t14
+// tagged "coffee" and t15 tagged "caffeine".
+func tagDataset() upgotest.Dataset {
+	ts := transactions(15)
+	for i := range ts {
+		switch {
+		case i < 12:
+			upgotest.AddTags(&ts[i], "cofee")
+		case i < 14:
+			upgotest.AddTags(&ts[i], "coffee")
+		default:
+			upgotest.AddTags(&ts[i], "caffeine")
+		}
+	}
+	return upgotest.Dataset{Transactions: ts}
+}
+
+// tagsByTransaction returns the tags of every transaction, keyed by ID.
+func tagsByTransaction(t *testing.T, c *upgo.Client) map[string][]string {
+	t.Helper()
+	trans, err := c.GetTransactions(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string][]string, len(trans))
+	for _, tr := range trans {
+		m[tr.Id] = tags(tr)
+	}
+	return m
+}
+
+func TestRenameTag(t *testing.T) {
+	_, c := newTestServer(t, tagDataset())
+	ctx := context.Background()
+	before := tagsByTransaction(t, c)
+
+	change, err := c.RenameTag(ctx, "cofee", "coffee", upgo.RetagOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(change.TransactionIDs) != 12 || change.Report != nil {
+		t.Errorf("dry run found %d transactions with report %v, want 12 and no report", len(change.TransactionIDs), change.Report)
+	}
+	if after := tagsByTransaction(t, c); !maps.EqualFunc(before, after, slices.Equal) {
+		t.Errorf("dry run changed tags from %v to %v", before, after)
+	}
+
+	change, err = c.RenameTag(ctx, "cofee", "coffee", upgo.RetagOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(change.TransactionIDs) != 12 || change.Report == nil || change.Report.Err() != nil {
+		t.Fatalf("rename changed %d transactions with report %v, want 12 without failures", len(change.TransactionIDs), change.Report)
+	}
+	after := tagsByTransaction(t, c)
+	for id, before := range before {
+		want := before
+		if slices.Equal(before, []string{"cofee"}) {
+			want = []string{"coffee"}
+		}
+		if !slices.Equal(after[id], want) {
+			t.Errorf("transaction %s has tags %v, want %v", id, after[id], want)
+		}
+	}
+
+	tags, err := c.GetTags(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags {
+		if tag.Id == "cofee" {
+			t.Error("renamed tag still exists")
+		}
+	}
+}
+
+func TestRenameTagUnused(t *testing.T) {
+	_, c := newTestServer(t, tagDataset())
+
+	change, err := c.RenameTag(context.Background(), "tea", "coffee", upgo.RetagOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(change.TransactionIDs) != 0 || change.Report == nil || len(change.Report.Results) != 0 {
+		t.Errorf("got change %+v, want no transactions and an empty report", change)
+	}
+}
+
+func TestRenameTagInvalid(t *testing.T) {
+	_, c := newTestServer(t, tagDataset())
+	ctx := context.Background()
+
+	for _, tt := range []struct{ from, to string }{{"coffee", "coffee"}, {"", "coffee"}, {"coffee", ""}} {
+		if _, err := c.RenameTag(ctx, tt.from, tt.to, upgo.RetagOptions{}); err == nil {
+			t.Errorf("RenameTag(%q, %q) succeeded, want error", tt.from, tt.to)
+		}
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	_, c := newTestServer(t, tagDataset())
+	ctx := context.Background()
+	before := tagsByTransaction(t, c)
+	from := []string{"cofee", "coffee", "caffeine"}
+
+	changes, err := c.MergeTags(ctx, from, "coffee", upgo.RetagOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || len(changes[0].TransactionIDs) != 12 || len(changes[1].TransactionIDs) != 1 || changes[0].Report != nil {
+		t.Errorf("dry run got changes %+v, want 12 cofee and 1 caffeine transactions without reports", changes)
+	}
+	if after := tagsByTransaction(t, c); !maps.EqualFunc(before, after, slices.Equal) {
+		t.Errorf("dry run changed tags from %v to %v", before, after)
+	}
+
+	// merging a tag into itself is skipped
+	changes, err = c.MergeTags(ctx, from, "coffee", upgo.RetagOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moved []string
+	for _, ch := range changes {
+		moved = append(moved, ch.From)
+	}
+	if !slices.Equal(moved, []string{"cofee", "caffeine"}) {
+		t.Errorf("got changes from %v, want [cofee caffeine]", moved)
+	}
+	for id, tags := range tagsByTransaction(t, c) {
+		if !slices.Equal(tags, []string{"coffee"}) {
+			t.Errorf("transaction %s has tags %v, want [coffee]", id, tags)
+		}
+	}
+
+	got, err := c.GetTags(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Id != "coffee" {
+		t.Errorf("got tags %v, want only coffee", got)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// SetCategory sets the category of the transaction identified by transactionID
+// to the category identified by categoryID, e.g. "restaurants-and-cafes".
+// An error matching [ErrNotCategorizable] is returned if the transaction does
+// not support categories.
+func (c *Client) SetCategory(ctx context.Context, transactionID, categoryID string) error {
+	c.logger.Info("SetCategory", "transactionID", transactionID, "categoryID", categoryID)
+	if err := c.checkCategorizable(ctx, transactionID); err != nil {
+		return err
+	}
+	return c.updateCategory(ctx, transactionID, &oapi.CategoryInputResourceIdentifier{
+		Id:   categoryID,
+		Type: "categories",
+	})
+}
+
+// ClearCategory removes the category from the transaction identified by transactionID.
+// An error matching [ErrNotCategorizable] is returned if the transaction does
+// not support categories.
+func (c *Client) ClearCategory(ctx context.Context, transactionID string) error {
+	c.logger.Info("ClearCategory", "transactionID", transactionID)
+	if err := c.checkCategorizable(ctx, transactionID); err != nil {
+		return err
+	}
+	return c.updateCategory(ctx, transactionID, nil)
+}
+
+// AddTags adds tags, identified by their labels, to the transaction identified by transactionID.
+// Tags which do not yet exist are created.
+func (c *Client) AddTags(ctx context.Context, transactionID string, tags ...string) error {
+	c.logger.Info("AddTags", "transactionID", transactionID, "tags", tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	resp, err := c.upClient.PostTransactionsTransactionIdRelationshipsTagsWithResponse(ctx, transactionID, tagsRequest(tags))
+	if err != nil {
+		return err
+	}
+	return checkNoContent("adding tags to transaction "+transactionID, resp.StatusCode(), resp.Body)
+}
+
+// RemoveTags removes tags, identified by their labels, from the transaction identified by transactionID.
+func (c *Client) RemoveTags(ctx context.Context, transactionID string, tags ...string) error {
+	c.logger.Info("RemoveTags", "transactionID", transactionID, "tags", tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	resp, err := c.upClient.DeleteTransactionsTransactionIdRelationshipsTagsWithResponse(ctx, transactionID, tagsRequest(tags))
+	if err != nil {
+		return err
+	}
+	return checkNoContent("removing tags from transaction "+transactionID, resp.StatusCode(), resp.Body)
+}
+
+// checkCategorizable fetches the transaction identified by transactionID and
+// returns an error if it does not support categories.
+func (c *Client) checkCategorizable(ctx context.Context, transactionID string) error {
+	t, err := c.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+	if !t.Attributes.IsCategorizable {
+		return fmt.Errorf("error categorizing transaction %s: %w", transactionID, ErrNotCategorizable)
+	}
+	return nil
+}
+
+// updateCategory sets the category of a transaction without first checking
+// that it is categorizable. A nil category clears it.
+func (c *Client) updateCategory(ctx context.Context, transactionID string, category *oapi.CategoryInputResourceIdentifier) error {
+	resp, err := c.upClient.PatchTransactionsTransactionIdRelationshipsCategoryWithResponse(ctx, transactionID, oapi.UpdateTransactionCategoryRequest{
+		Data: category,
+	})
+	if err != nil {
+		return err
+	}
+	return checkNoContent("categorizing transaction "+transactionID, resp.StatusCode(), resp.Body)
+}
+
+func tagsRequest(tags []string) oapi.UpdateTransactionTagsRequest {
+	req := oapi.UpdateTransactionTagsRequest{
+		Data: make([]oapi.TagInputResourceIdentifier, 0, len(tags)),
+	}
+	for _, tag := range tags {
+		req.Data = append(req.Data, oapi.TagInputResourceIdentifier{
+			Id:   tag,
+			Type: "tags",
+		})
+	}
+	return req
+}
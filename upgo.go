@@ -18,8 +18,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/porjo/upgo/oapi"
@@ -32,12 +34,26 @@ const (
 	BaseUnitDivisor = 100 // amounts are in cents
 )
 
+const defaultPingTimeout = 5 * time.Second
+
 type Client struct {
 	client   *http.Client
 	upClient *oapi.ClientWithResponses
 
 	token string
 
+	baseURL     string
+	httpClient  *http.Client
+	transport   http.RoundTripper
+	pingTimeout time.Duration
+	skipPing    bool
+
+	retryPolicy *RetryPolicy
+	limiter     *tokenBucket
+
+	categoriesMu sync.Mutex
+	categories   *CategoryTree
+
 	logger *slog.Logger
 }
 
@@ -58,12 +74,63 @@ func WithToken(token string) ClientOption {
 	}
 }
 
+// WithBaseURL overrides the API server URL, which defaults to [ServerURL].
+// This is useful for pointing upgo at a fake server in tests.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient supplies the [http.Client] used to make requests. Its
+// settings such as Timeout are retained, and the API auth token is added to
+// requests sent through its Transport.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// WithTransport supplies the [http.RoundTripper] used to make requests,
+// taking precedence over the Transport of any client given to [WithHTTPClient].
+// The API auth token is added to requests before they reach transport.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithPingTimeout sets how long [NewClient] waits for the API to respond to
+// the initial ping. The default is 5 seconds. A value of zero or less means
+// no timeout is applied beyond that of the context given to [NewClientContext].
+func WithPingTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.pingTimeout = timeout
+	}
+}
+
+// WithoutPing stops [NewClient] from pinging the API, so that a Client can be
+// constructed without network access.
+func WithoutPing() ClientOption {
+	return func(c *Client) {
+		c.skipPing = true
+	}
+}
+
 // NewClient returns a Client
 // It will use a default [slog.Logger] log handler unless overriden with [WithLogger]
 func NewClient(opts ...ClientOption) (*Client, error) {
+	return NewClientContext(context.Background(), opts...)
+}
+
+// NewClientContext is like [NewClient], using ctx for the initial ping of the API.
+func NewClientContext(ctx context.Context, opts ...ClientOption) (*Client, error) {
 
 	var err error
-	c := &Client{}
+	c := &Client{
+		baseURL:     ServerURL,
+		pingTimeout: defaultPingTimeout,
+	}
 
 	// by default log is discarded
 	c.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
@@ -73,23 +140,55 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 		opt(c)
 	}
 
+	// oauth2 uses the transport and settings of this client underneath its own
+	base := &http.Client{}
+	if c.httpClient != nil {
+		*base = *c.httpClient
+	}
+	if c.transport != nil {
+		base.Transport = c.transport
+	}
+
 	// setting bearer token via roundtripper is a bit tricky
 	// let oauth2 package take care of that for us
 	// see: https://stackoverflow.com/a/51326483/202311
-	c.client = oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{
+	c.client = oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, base), oauth2.StaticTokenSource(&oauth2.Token{
 		AccessToken: c.token,
 		TokenType:   "Bearer",
 	}))
 
-	c.upClient, err = oapi.NewClientWithResponses(ServerURL, oapi.WithHTTPClient(c.client))
+	if c.limiter != nil {
+		c.client.Transport = &rateLimitTransport{
+			next:    c.client.Transport,
+			limiter: c.limiter,
+		}
+	}
+	// retries wrap the rate limiter so that every attempt is limited
+	if c.retryPolicy != nil {
+		c.client.Transport = &retryTransport{
+			next:   c.client.Transport,
+			policy: *c.retryPolicy,
+			logger: c.logger,
+		}
+	}
+
+	c.upClient, err = oapi.NewClientWithResponses(c.baseURL, oapi.WithHTTPClient(c.client))
 	if err != nil {
 		return nil, fmt.Errorf("error getting client: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	if c.skipPing {
+		return c, nil
+	}
 
-	_, err = c.upClient.GetUtilPing(ctx)
+	if c.pingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.pingTimeout)
+		defer cancel()
+	}
+
+	// an invalid token is reported here as an error matching ErrUnauthorized
+	_, err = c.ping(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error pinging API: %w", err)
 	}
@@ -97,36 +196,132 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 	return c, nil
 }
 
+// Identity identifies the customer that an API token belongs to.
+type Identity struct {
+	// ID is the unique identifier of the authenticated customer.
+	ID string
+	// StatusEmoji is a cute emoji that represents the response status.
+	StatusEmoji string
+}
+
+// WhoAmI pings the API to verify the API token, returning the [Identity] of the customer it
+// belongs to. An invalid token results in an error matching [ErrUnauthorized].
+func (c *Client) WhoAmI(ctx context.Context) (*Identity, error) {
+	c.logger.Info("WhoAmI")
+	return c.ping(ctx)
+}
+
+func (c *Client) ping(ctx context.Context) (*Identity, error) {
+	resp, err := c.upClient.GetUtilPingWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse("ping", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		ID:          resp.JSON200.Meta.Id,
+		StatusEmoji: resp.JSON200.Meta.StatusEmoji,
+	}, nil
+}
+
+// GetAccounts returns all accounts.
 func (c *Client) GetAccounts(ctx context.Context) ([]oapi.AccountResource, error) {
 	c.logger.Info("GetAccounts")
-	resp, err := c.upClient.GetAccountsWithResponse(ctx, &oapi.GetAccountsParams{})
+	return collect(ctx, c.AccountsPager(&oapi.GetAccountsParams{}), pageConfig{})
+}
+
+// GetAccount returns the account identified by id.
+// [ErrNotFound] is returned if there is no such account.
+func (c *Client) GetAccount(ctx context.Context, id string) (*oapi.AccountResource, error) {
+	c.logger.Info("GetAccount", "id", id)
+	resp, err := c.upClient.GetAccountsIdWithResponse(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if resp == nil || resp.JSON200 == nil {
-		return nil, fmt.Errorf("error getting accounts: response is nil")
-	}
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("error getting accounts. Expected HTTP 200 but received %d", resp.StatusCode())
+	if err := checkResponse("account", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+		return nil, err
 	}
-
-	return resp.JSON200.Data, nil
+	return &resp.JSON200.Data, nil
 }
 
 // GetTransactions returns transactions for all accounts, optionally filtered by [oapi.GetTransactionsParams].
-func (c *Client) GetTransactions(ctx context.Context, params *oapi.GetTransactionsParams) ([]oapi.TransactionResource, error) {
+// All pages of results are fetched by following the `next` link of each page, unless limited by
+// [WithMaxPages] or [WithMaxRecords].
+func (c *Client) GetTransactions(ctx context.Context, params *oapi.GetTransactionsParams, opts ...PageOption) ([]oapi.TransactionResource, error) {
 	c.logger.Info("GetTransactions")
-	resp, err := c.upClient.GetTransactionsWithResponse(ctx, params)
+	return collect(ctx, c.TransactionsPager(params), newPageConfig(opts))
+}
+
+// Transactions returns an iterator over transactions for all accounts, optionally filtered by
+// [oapi.GetTransactionsParams]. Unlike [Client.GetTransactions], pages are fetched lazily as the
+// iterator is consumed, and no further pages are fetched once the loop is exited.
+// If an error occurs it is yielded once and iteration stops.
+func (c *Client) Transactions(ctx context.Context, params *oapi.GetTransactionsParams) iter.Seq2[oapi.TransactionResource, error] {
+	c.logger.Info("Transactions")
+	return c.TransactionsPager(params).All(ctx)
+}
+
+// GetAccountTransactions returns transactions for the account identified by accountID, optionally
+// filtered by [oapi.GetAccountsAccountIdTransactionsParams]. Pagination behaves as for
+// [Client.GetTransactions].
+func (c *Client) GetAccountTransactions(ctx context.Context, accountID string, params *oapi.GetAccountsAccountIdTransactionsParams, opts ...PageOption) ([]oapi.TransactionResource, error) {
+	c.logger.Info("GetAccountTransactions", "accountID", accountID)
+	return collect(ctx, c.AccountTransactionsPager(accountID, params), newPageConfig(opts))
+}
+
+// GetTransaction returns the transaction identified by id.
+// [ErrNotFound] is returned if there is no such transaction.
+func (c *Client) GetTransaction(ctx context.Context, id string) (*oapi.TransactionResource, error) {
+	c.logger.Info("GetTransaction", "id", id)
+	resp, err := c.upClient.GetTransactionsIdWithResponse(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if resp == nil || resp.JSON200 == nil {
-		return nil, fmt.Errorf("error getting transactions: response is nil")
+	if err := checkResponse("transaction", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+		return nil, err
 	}
+	return &resp.JSON200.Data, nil
+}
 
-	if resp.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("error getting transactions. Expected HTTP 200 but received %d", resp.StatusCode())
+// GetCategory returns the category identified by id, e.g. "restaurants-and-cafes".
+// [ErrNotFound] is returned if there is no such category.
+func (c *Client) GetCategory(ctx context.Context, id string) (*oapi.CategoryResource, error) {
+	c.logger.Info("GetCategory", "id", id)
+	resp, err := c.upClient.GetCategoriesIdWithResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse("category", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+		return nil, err
 	}
+	return &resp.JSON200.Data, nil
+}
 
-	return resp.JSON200.Data, nil
+// GetAttachment returns the attachment identified by id.
+// [ErrNotFound] is returned if there is no such attachment.
+func (c *Client) GetAttachment(ctx context.Context, id string) (*oapi.AttachmentResource, error) {
+	c.logger.Info("GetAttachment", "id", id)
+	resp, err := c.upClient.GetAttachmentsIdWithResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse("attachment", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return &resp.JSON200.Data, nil
+}
+
+// GetWebhook returns the webhook identified by id.
+// [ErrNotFound] is returned if there is no such webhook.
+func (c *Client) GetWebhook(ctx context.Context, id string) (*oapi.WebhookResource, error) {
+	c.logger.Info("GetWebhook", "id", id)
+	resp, err := c.upClient.GetWebhooksIdWithResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse("webhook", resp.StatusCode(), resp.Body, resp.JSON200 != nil); err != nil {
+		return nil, err
+	}
+	return &resp.JSON200.Data, nil
 }
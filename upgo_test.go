@@ -0,0 +1,96 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/upgotest"
+)
+
+func TestGetResource(t *testing.T) {
+	_, c := newTestServer(t, upgotest.Dataset{
+		Accounts:     []oapi.AccountResource{upgotest.Account("account", "Spending", oapi.TRANSACTIONAL, 10000)},
+		Transactions: transactions(1),
+		Categories:   []oapi.CategoryResource{upgotest.Category("groceries", "Groceries", "")},
+		Attachments:  []oapi.AttachmentResource{upgotest.Attachment("attachment", "t1")},
+		Webhooks:     []oapi.WebhookResource{upgotest.Webhook("webhook", "https://example.com", "")},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		id   string
+		get  func(id string) (string, error)
+	}{
+		{"account", "account", func(id string) (string, error) {
+			r, err := c.GetAccount(ctx, id)
+			if err != nil {
+				return "", err
+			}
+			return r.Id, nil
+		}},
+		{"transaction", "t1", func(id string) (string, error) {
+			r, err := c.GetTransaction(ctx, id)
+			if err != nil {
+				return "", err
+			}
+			return r.Id, nil
+		}},
+		{"category", "groceries", func(id string) (string, error) {
+			r, err := c.GetCategory(ctx, id)
+			if err != nil {
+				return "", err
+			}
+			return r.Id, nil
+		}},
+		{"attachment", "attachment", func(id string) (string, error) {
+			r, err := c.GetAttachment(ctx, id)
+			if err != nil {
+				return "", err
+			}
+			return r.Id, nil
+		}},
+		{"webhook", "webhook", func(id string) (string, error) {
+			r, err := c.GetWebhook(ctx, id)
+			if err != nil {
+				return "", err
+			}
+			return r.Id, nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get(tt.id)
+			if err != nil || got != tt.id {
+				t.Errorf("got %q, %v, want %q", got, err, tt.id)
+			}
+
+			_, err = tt.get("missing")
+			if !errors.Is(err, upgo.ErrNotFound) {
+				t.Errorf("got error %v, want %v", err, upgo.ErrNotFound)
+			}
+			var apiErr *upgo.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || len(apiErr.Errors) == 0 {
+				t.Errorf("got error %#v, want APIError with HTTP 404 and its error objects", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgotest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// Dataset is the data served by a [Server]. The builders in this package,
+// such as [Account] and [Transaction], make resources which are valid for the
+// API. Relationships derived from others, such as the children of a category
+// or the parent category of a transaction, are filled in by the server.
+type Dataset struct {
+	Accounts     []oapi.AccountResource
+	Transactions []oapi.TransactionResource
+	Categories   []oapi.CategoryResource
+	Attachments  []oapi.AttachmentResource
+	Webhooks     []oapi.WebhookResource
+
+	// DeliveryLogs holds the delivery logs of each webhook, keyed by webhook
+	// ID, most recent first.
+	DeliveryLogs map[string][]oapi.WebhookDeliveryLogResource
+}
+
+// Money returns an amount of Australian dollars given in cents.
+func Money(baseUnits int64) oapi.MoneyObject {
+	sign := ""
+	abs := baseUnits
+	if baseUnits < 0 {
+		sign = "-"
+		abs = -baseUnits
+	}
+	return oapi.MoneyObject{
+		CurrencyCode:     "AUD",
+		Value:            fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100),
+		ValueInBaseUnits: baseUnits,
+	}
+}
+
+// Account returns an individually owned account with a balance given in cents.
+func Account(id, displayName string, accountType oapi.AccountTypeEnum, balance int64) oapi.AccountResource {
+	var a oapi.AccountResource
+	a.Type = "accounts"
+	a.Id = id
+	a.Attributes.DisplayName = displayName
+	a.Attributes.AccountType = accountType
+	a.Attributes.OwnershipType = oapi.INDIVIDUAL
+	a.Attributes.Balance = Money(balance)
+	a.Attributes.CreatedAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return a
+}
+
+// Transaction returns a categorizable, settled transaction on the account
+// identified by accountID, with an amount given in cents.
+func Transaction(id, accountID, description string, amount int64, createdAt time.Time) oapi.TransactionResource {
+	var t oapi.TransactionResource
+	t.Type = "transactions"
+	t.Id = id
+	t.Attributes.Description = description
+	t.Attributes.Amount = Money(amount)
+	t.Attributes.CreatedAt = createdAt
+	t.Attributes.SettledAt = &createdAt
+	t.Attributes.Status = oapi.SETTLED
+	t.Attributes.IsCategorizable = true
+	t.Relationships.Account.Data.Type = "accounts"
+	t.Relationships.Account.Data.Id = accountID
+	return t
+}
+
+// SetCategory sets the category of t to that identified by categoryID, or
+// clears it if categoryID is empty.
+func SetCategory(t *oapi.TransactionResource, categoryID string) {
+	t.Relationships.Category.Data = categoryIdentifier(categoryID)
+}
+
+// AddTags adds the tags labelled tags to t.
+func AddTags(t *oapi.TransactionResource, tags ...string) {
+	for _, tag := range tags {
+		if !hasTag(*t, tag) {
+			t.Relationships.Tags.Data = append(t.Relationships.Tags.Data, struct {
+				Id   string `json:"id"`
+				Type string `json:"type"`
+			}{Id: tag, Type: "tags"})
+		}
+	}
+}
+
+// Category returns a category which is a child of the category identified by
+// parentID, or a parent category if parentID is empty.
+func Category(id, name, parentID string) oapi.CategoryResource {
+	var c oapi.CategoryResource
+	c.Type = "categories"
+	c.Id = id
+	c.Attributes.Name = name
+	c.Relationships.Parent.Data = categoryIdentifier(parentID)
+	return c
+}
+
+// Attachment returns an attachment of the transaction identified by
+// transactionID.
+func Attachment(id, transactionID string) oapi.AttachmentResource {
+	var a oapi.AttachmentResource
+	a.Type = "attachments"
+	a.Id = id
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	contentType, ext := "image/jpeg", "jpg"
+	fileURL := "https://example.com/attachments/" + id + "." + ext
+	a.Attributes.CreatedAt = &created
+	a.Attributes.FileContentType = &contentType
+	a.Attributes.FileExtension = &ext
+	a.Attributes.FileURL = &fileURL
+	a.Attributes.FileURLExpiresAt = time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	a.Relationships.Transaction.Data.Type = "transactions"
+	a.Relationships.Transaction.Data.Id = transactionID
+	return a
+}
+
+// Webhook returns a webhook posting events to url.
+func Webhook(id, url, description string) oapi.WebhookResource {
+	var wh oapi.WebhookResource
+	wh.Type = "webhooks"
+	wh.Id = id
+	wh.Attributes.Url = url
+	if description != "" {
+		wh.Attributes.Description = &description
+	}
+	wh.Attributes.CreatedAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return wh
+}
+
+// DeliveryLog returns the log of a delivery with the given status and
+// response status code. A status code of zero means no response was received.
+func DeliveryLog(id string, status oapi.WebhookDeliveryStatusEnum, statusCode int, createdAt time.Time) oapi.WebhookDeliveryLogResource {
+	var l oapi.WebhookDeliveryLogResource
+	l.Type = "webhook-delivery-logs"
+	l.Id = id
+	l.Attributes.CreatedAt = createdAt
+	l.Attributes.DeliveryStatus = status
+	l.Attributes.Request.Body = "{}"
+	if statusCode != 0 {
+		l.Attributes.Response = &struct {
+			Body       string `json:"body"`
+			StatusCode int    `json:"statusCode"`
+		}{StatusCode: statusCode}
+	}
+	l.Relationships.WebhookEvent.Data.Type = "webhook-events"
+	l.Relationships.WebhookEvent.Data.Id = id
+	return l
+}
+
+func categoryIdentifier(id string) *struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+} {
+	if id == "" {
+		return nil
+	}
+	return &struct {
+		Id   string `json:"id"`
+		Type string `json:"type"`
+	}{Id: id, Type: "categories"}
+}
+
+func hasTag(t oapi.TransactionResource, tag string) bool {
+	for _, d := range t.Relationships.Tags.Data {
+		if d.Id == tag {
+			return true
+		}
+	}
+	return false
+}
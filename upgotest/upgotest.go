@@ -0,0 +1,760 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package upgotest provides a fake Up API server for testing code built on
+// [upgo.Client], without a real token or network access.
+//
+// The [Server] serves the endpoints of the API over an in-memory [Dataset],
+// checking the bearer token of each request. Lists are paginated with
+// prev and next links as the API does, and filters behave as documented.
+// Changes made through the API, such as tagging a transaction or creating a
+// webhook, are applied to the dataset.
+package upgotest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/webhook/webhooktest"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+
+	// maxTags is the most tags a transaction may carry.
+	maxTags = 6
+)
+
+// Server is a fake Up API server. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	token string
+
+	mu           sync.Mutex
+	accounts     []oapi.AccountResource
+	transactions []oapi.TransactionResource
+	categories   []oapi.CategoryResource
+	attachments  []oapi.AttachmentResource
+	webhooks     []oapi.WebhookResource
+	logs         map[string][]oapi.WebhookDeliveryLogResource
+}
+
+// NewServer starts a Server serving data, which only accepts requests
+// bearing token. The caller should call Close when finished, to shut it
+// down.
+func NewServer(token string, data Dataset) *Server {
+	s := &Server{
+		token:        token,
+		accounts:     slices.Clone(data.Accounts),
+		transactions: slices.Clone(data.Transactions),
+		categories:   slices.Clone(data.Categories),
+		attachments:  slices.Clone(data.Attachments),
+		webhooks:     slices.Clone(data.Webhooks),
+		logs:         make(map[string][]oapi.WebhookDeliveryLogResource),
+	}
+	for id, logs := range data.DeliveryLogs {
+		s.logs[id] = slices.Clone(logs)
+	}
+	s.linkCategories()
+	for i := range s.transactions {
+		s.linkParentCategory(&s.transactions[i])
+	}
+	s.sortTransactions()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /util/ping", s.ping)
+	mux.HandleFunc("GET /accounts", s.listAccounts)
+	mux.HandleFunc("GET /accounts/{id}", s.getAccount)
+	mux.HandleFunc("GET /accounts/{id}/transactions", s.listAccountTransactions)
+	mux.HandleFunc("GET /transactions", s.listTransactions)
+	mux.HandleFunc("GET /transactions/{id}", s.getTransaction)
+	mux.HandleFunc("PATCH /transactions/{id}/relationships/category", s.updateCategory)
+	mux.HandleFunc("POST /transactions/{id}/relationships/tags", s.addTags)
+	mux.HandleFunc("DELETE /transactions/{id}/relationships/tags", s.removeTags)
+	mux.HandleFunc("GET /categories", s.listCategories)
+	mux.HandleFunc("GET /categories/{id}", s.getCategory)
+	mux.HandleFunc("GET /tags", s.listTags)
+	mux.HandleFunc("GET /attachments", s.listAttachments)
+	mux.HandleFunc("GET /attachments/{id}", s.getAttachment)
+	mux.HandleFunc("GET /webhooks", s.listWebhooks)
+	mux.HandleFunc("POST /webhooks", s.createWebhook)
+	mux.HandleFunc("GET /webhooks/{id}", s.getWebhook)
+	mux.HandleFunc("DELETE /webhooks/{id}", s.deleteWebhook)
+	mux.HandleFunc("GET /webhooks/{id}/logs", s.listDeliveryLogs)
+	mux.HandleFunc("POST /webhooks/{id}/ping", s.pingWebhook)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Not Found", "The requested resource could not be found.", nil)
+	})
+
+	s.Server = httptest.NewServer(s.authenticate(mux))
+	return s
+}
+
+// NewClient returns a [upgo.Client] using the server with its token. Further
+// options are applied after those.
+func (s *Server) NewClient(opts ...upgo.ClientOption) (*upgo.Client, error) {
+	return upgo.NewClient(append([]upgo.ClientOption{upgo.WithBaseURL(s.URL), upgo.WithToken(s.token)}, opts...)...)
+}
+
+// Transaction returns the transaction identified by id.
+func (s *Server) Transaction(id string) (oapi.TransactionResource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.transactionIndex(id)
+	if i < 0 {
+		return oapi.TransactionResource{}, false
+	}
+	return s.transactions[i], true
+}
+
+// AddTransaction adds t to the dataset, replacing any transaction with the
+// same ID.
+func (s *Server) AddTransaction(t oapi.TransactionResource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.linkParentCategory(&t)
+	if i := s.transactionIndex(t.Id); i >= 0 {
+		s.transactions[i] = t
+	} else {
+		s.transactions = append(s.transactions, t)
+	}
+	s.sortTransactions()
+}
+
+// UpdateTransaction calls fn to modify the transaction identified by id,
+// reporting whether it exists.
+func (s *Server) UpdateTransaction(id string, fn func(t *oapi.TransactionResource)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.transactionIndex(id)
+	if i < 0 {
+		return false
+	}
+	fn(&s.transactions[i])
+	s.linkParentCategory(&s.transactions[i])
+	s.sortTransactions()
+	return true
+}
+
+// DeleteTransaction removes the transaction identified by id, reporting
+// whether it existed.
+func (s *Server) DeleteTransaction(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.transactionIndex(id)
+	if i < 0 {
+		return false
+	}
+	s.transactions = slices.Delete(s.transactions, i, i+1)
+	return true
+}
+
+// Webhooks returns the webhooks which currently exist.
+func (s *Server) Webhooks() []oapi.WebhookResource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.webhooks)
+}
+
+// AddDeliveryLog records l as the most recent delivery to the webhook
+// identified by webhookID.
+func (s *Server) AddDeliveryLog(webhookID string, l oapi.WebhookDeliveryLogResource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs[webhookID] = slices.Insert(s.logs[webhookID], 0, l)
+}
+
+// authenticate rejects requests without the server's bearer token.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeError(w, http.StatusUnauthorized, "Not Authorized",
+				"The request was not authenticated because no valid credential was found in the Authorization header, or the Authorization header was not present.", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) ping(w http.ResponseWriter, r *http.Request) {
+	var resp oapi.PingResponse
+	resp.Meta.Id = "00000000-0000-4000-8000-000000000000"
+	resp.Meta.StatusEmoji = "⚡️"
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) listAccounts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	accountType := oapi.AccountTypeEnum(q.Get("filter[accountType]"))
+	if accountType != "" && !accountType.Valid() {
+		writeParamError(w, "filter[accountType]", "The account type filter is invalid.")
+		return
+	}
+	ownershipType := oapi.OwnershipTypeEnum(q.Get("filter[ownershipType]"))
+	if ownershipType != "" && !ownershipType.Valid() {
+		writeParamError(w, "filter[ownershipType]", "The ownership type filter is invalid.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var accounts []oapi.AccountResource
+	for _, a := range s.accounts {
+		if (accountType == "" || a.Attributes.AccountType == accountType) &&
+			(ownershipType == "" || a.Attributes.OwnershipType == ownershipType) {
+			accounts = append(accounts, a)
+		}
+	}
+	writePage(w, r, accounts, func(a oapi.AccountResource) string { return a.Id })
+}
+
+func (s *Server) getAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.accounts {
+		if a.Id == r.PathValue("id") {
+			writeJSON(w, http.StatusOK, oapi.GetAccountResponse{Data: a})
+			return
+		}
+	}
+	writeNotFound(w, "account")
+}
+
+func (s *Server) listAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	if !slices.ContainsFunc(s.accounts, func(a oapi.AccountResource) bool { return a.Id == id }) {
+		writeNotFound(w, "account")
+		return
+	}
+	s.writeTransactions(w, r, id)
+}
+
+func (s *Server) listTransactions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeTransactions(w, r, "")
+}
+
+// writeTransactions writes a page of the transactions matching the filters of
+// r, limited to the account identified by accountID unless it is empty.
+func (s *Server) writeTransactions(w http.ResponseWriter, r *http.Request, accountID string) {
+	q := r.URL.Query()
+
+	status := oapi.TransactionStatusEnum(q.Get("filter[status]"))
+	if status != "" && !status.Valid() {
+		writeParamError(w, "filter[status]", "The status filter is invalid.")
+		return
+	}
+	var since, until time.Time
+	for param, t := range map[string]*time.Time{"filter[since]": &since, "filter[until]": &until} {
+		if v := q.Get(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeParamError(w, param, "The date-time is not formatted according to rfc-3339.")
+				return
+			}
+			*t = parsed
+		}
+	}
+	category := q.Get("filter[category]")
+	if category != "" && s.categoryIndex(category) < 0 {
+		writeError(w, http.StatusNotFound, "Not Found", "The category filter refers to a category which does not exist.", &sourceParam{parameter: "filter[category]"})
+		return
+	}
+	tag := q.Get("filter[tag]")
+
+	var trans []oapi.TransactionResource
+	for _, t := range s.transactions {
+		a := t.Attributes
+		switch {
+		case accountID != "" && t.Relationships.Account.Data.Id != accountID,
+			status != "" && a.Status != status,
+			!since.IsZero() && a.CreatedAt.Before(since),
+			!until.IsZero() && !a.CreatedAt.Before(until),
+			category != "" && !inCategory(t, category),
+			tag != "" && !hasTag(t, tag):
+			continue
+		}
+		trans = append(trans, t)
+	}
+	writePage(w, r, trans, func(t oapi.TransactionResource) string { return t.Id })
+}
+
+func (s *Server) getTransaction(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.transactionIndex(r.PathValue("id"))
+	if i < 0 {
+		writeNotFound(w, "transaction")
+		return
+	}
+	writeJSON(w, http.StatusOK, oapi.GetTransactionResponse{Data: s.transactions[i]})
+}
+
+func (s *Server) updateCategory(w http.ResponseWriter, r *http.Request) {
+	var req oapi.UpdateTransactionCategoryRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.transactionIndex(r.PathValue("id"))
+	if i < 0 {
+		writeNotFound(w, "transaction")
+		return
+	}
+	t := &s.transactions[i]
+	if !t.Attributes.IsCategorizable {
+		writeError(w, http.StatusForbidden, "Forbidden", "This transaction cannot be categorized.", nil)
+		return
+	}
+
+	categoryID := ""
+	if req.Data != nil {
+		categoryID = req.Data.Id
+		if s.categoryIndex(categoryID) < 0 {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid Attribute", "The category does not exist.", &sourceParam{pointer: "/data/id"})
+			return
+		}
+	}
+	SetCategory(t, categoryID)
+	s.linkParentCategory(t)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) addTags(w http.ResponseWriter, r *http.Request) {
+	s.updateTags(w, r, true)
+}
+
+func (s *Server) removeTags(w http.ResponseWriter, r *http.Request) {
+	s.updateTags(w, r, false)
+}
+
+func (s *Server) updateTags(w http.ResponseWriter, r *http.Request, add bool) {
+	var req oapi.UpdateTransactionTagsRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.transactionIndex(r.PathValue("id"))
+	if i < 0 {
+		writeNotFound(w, "transaction")
+		return
+	}
+	t := &s.transactions[i]
+
+	for j, tag := range req.Data {
+		if tag.Id == "" || tag.Type != "tags" {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid Attribute", "The tag identifier is invalid.", &sourceParam{pointer: fmt.Sprintf("/data/%d", j)})
+			return
+		}
+	}
+
+	if !add {
+		t.Relationships.Tags.Data = slices.DeleteFunc(t.Relationships.Tags.Data, func(d struct {
+			Id   string `json:"id"`
+			Type string `json:"type"`
+		}) bool {
+			return slices.ContainsFunc(req.Data, func(tag oapi.TagInputResourceIdentifier) bool { return tag.Id == d.Id })
+		})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	updated := *t
+	updated.Relationships.Tags.Data = slices.Clone(t.Relationships.Tags.Data)
+	for _, tag := range req.Data {
+		AddTags(&updated, tag.Id)
+	}
+	if len(updated.Relationships.Tags.Data) > maxTags {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid Attribute", fmt.Sprintf("A transaction may have at most %d tags.", maxTags), &sourceParam{pointer: "/data"})
+		return
+	}
+	*t = updated
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listCategories(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent := r.URL.Query().Get("filter[parent]")
+	if parent != "" && s.categoryIndex(parent) < 0 {
+		writeError(w, http.StatusNotFound, "Not Found", "The parent filter refers to a category which does not exist.", &sourceParam{parameter: "filter[parent]"})
+		return
+	}
+	cats := []oapi.CategoryResource{}
+	for _, c := range s.categories {
+		if parent == "" || (c.Relationships.Parent.Data != nil && c.Relationships.Parent.Data.Id == parent) {
+			cats = append(cats, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, oapi.ListCategoriesResponse{Data: cats})
+}
+
+func (s *Server) getCategory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.categoryIndex(r.PathValue("id"))
+	if i < 0 {
+		writeNotFound(w, "category")
+		return
+	}
+	writeJSON(w, http.StatusOK, oapi.GetCategoryResponse{Data: s.categories[i]})
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// tags exist only while a transaction carries them
+	var labels []string
+	for _, t := range s.transactions {
+		for _, d := range t.Relationships.Tags.Data {
+			labels = append(labels, d.Id)
+		}
+	}
+	slices.Sort(labels)
+	labels = slices.Compact(labels)
+
+	base := baseURL(r)
+	tags := make([]oapi.TagResource, 0, len(labels))
+	for _, label := range labels {
+		var tag oapi.TagResource
+		tag.Type = "tags"
+		tag.Id = label
+		tag.Relationships.Transactions.Links = &struct {
+			Related string `json:"related"`
+		}{Related: base + "/transactions?" + url.Values{"filter[tag]": {label}}.Encode()}
+		tags = append(tags, tag)
+	}
+	writePage(w, r, tags, func(t oapi.TagResource) string { return t.Id })
+}
+
+func (s *Server) listAttachments(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writePage(w, r, s.attachments, func(a oapi.AttachmentResource) string { return a.Id })
+}
+
+func (s *Server) getAttachment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.attachments {
+		if a.Id == r.PathValue("id") {
+			writeJSON(w, http.StatusOK, oapi.GetAttachmentResponse{Data: a})
+			return
+		}
+	}
+	writeNotFound(w, "attachment")
+}
+
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writePage(w, r, s.webhooks, func(wh oapi.WebhookResource) string { return wh.Id })
+}
+
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req oapi.CreateWebhookRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	attrs := req.Data.Attributes
+	u, err := url.Parse(attrs.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || utf8.RuneCountInString(attrs.Url) > upgo.MaxWebhookURLLength {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid Attribute", "The URL must be a valid HTTP or HTTPS URL of at most 300 characters.", &sourceParam{pointer: "/data/attributes/url"})
+		return
+	}
+	if attrs.Description != nil && utf8.RuneCountInString(*attrs.Description) > upgo.MaxWebhookDescriptionLength {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid Attribute", "The description must be at most 64 characters.", &sourceParam{pointer: "/data/attributes/description"})
+		return
+	}
+
+	wh := Webhook(webhooktest.NewID(), attrs.Url, "")
+	wh.Attributes.Description = attrs.Description
+	wh.Attributes.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	s.mu.Lock()
+	s.webhooks = append(s.webhooks, wh)
+	s.mu.Unlock()
+
+	// the secret key is only ever returned on creation
+	key := newSecretKey()
+	wh.Attributes.SecretKey = &key
+	writeJSON(w, http.StatusCreated, oapi.CreateWebhookResponse{Data: wh})
+}
+
+func (s *Server) getWebhook(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.webhookIndex(r.PathValue("id"))
+	if i < 0 {
+		writeNotFound(w, "webhook")
+		return
+	}
+	writeJSON(w, http.StatusOK, oapi.GetWebhookResponse{Data: s.webhooks[i]})
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	i := s.webhookIndex(id)
+	if i < 0 {
+		writeNotFound(w, "webhook")
+		return
+	}
+	s.webhooks = slices.Delete(s.webhooks, i, i+1)
+	delete(s.logs, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listDeliveryLogs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	if s.webhookIndex(id) < 0 {
+		writeNotFound(w, "webhook")
+		return
+	}
+	writePage(w, r, s.logs[id], func(l oapi.WebhookDeliveryLogResource) string { return l.Id })
+}
+
+func (s *Server) pingWebhook(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	if s.webhookIndex(id) < 0 {
+		writeNotFound(w, "webhook")
+		return
+	}
+	cb := webhooktest.Ping(id)
+	writeJSON(w, http.StatusCreated, cb)
+}
+
+// linkCategories fills in the children of each category from the parents.
+func (s *Server) linkCategories() {
+	for i := range s.categories {
+		s.categories[i].Relationships.Children.Data = []struct {
+			Id   string `json:"id"`
+			Type string `json:"type"`
+		}{}
+	}
+	for _, c := range s.categories {
+		if c.Relationships.Parent.Data == nil {
+			continue
+		}
+		if i := s.categoryIndex(c.Relationships.Parent.Data.Id); i >= 0 {
+			parent := &s.categories[i]
+			parent.Relationships.Children.Data = append(parent.Relationships.Children.Data, struct {
+				Id   string `json:"id"`
+				Type string `json:"type"`
+			}{Id: c.Id, Type: "categories"})
+		}
+	}
+}
+
+// linkParentCategory sets the parent category of t from its category.
+func (s *Server) linkParentCategory(t *oapi.TransactionResource) {
+	t.Relationships.ParentCategory.Data = nil
+	if t.Relationships.Category.Data == nil {
+		return
+	}
+	if i := s.categoryIndex(t.Relationships.Category.Data.Id); i >= 0 {
+		if parent := s.categories[i].Relationships.Parent.Data; parent != nil {
+			t.Relationships.ParentCategory.Data = categoryIdentifier(parent.Id)
+		}
+	}
+}
+
+// sortTransactions orders transactions newest first, as the API lists them.
+func (s *Server) sortTransactions() {
+	slices.SortStableFunc(s.transactions, func(a, b oapi.TransactionResource) int {
+		return b.Attributes.CreatedAt.Compare(a.Attributes.CreatedAt)
+	})
+}
+
+func (s *Server) transactionIndex(id string) int {
+	return slices.IndexFunc(s.transactions, func(t oapi.TransactionResource) bool { return t.Id == id })
+}
+
+func (s *Server) categoryIndex(id string) int {
+	return slices.IndexFunc(s.categories, func(c oapi.CategoryResource) bool { return c.Id == id })
+}
+
+func (s *Server) webhookIndex(id string) int {
+	return slices.IndexFunc(s.webhooks, func(wh oapi.WebhookResource) bool { return wh.Id == id })
+}
+
+// inCategory reports whether t is in the category identified by id, either
+// directly or through its parent category.
+func inCategory(t oapi.TransactionResource, id string) bool {
+	rel := t.Relationships
+	return (rel.Category.Data != nil && rel.Category.Data.Id == id) ||
+		(rel.ParentCategory.Data != nil && rel.ParentCategory.Data.Id == id)
+}
+
+type listLinks struct {
+	Next *string `json:"next"`
+	Prev *string `json:"prev"`
+}
+
+type listResponse[T any] struct {
+	Data  []T       `json:"data"`
+	Links listLinks `json:"links"`
+}
+
+// writePage writes the page of items selected by the page parameters of r.
+// Pages are identified by the ID of the resource they follow or precede, and
+// the links to other pages keep the rest of the query.
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T, id func(T) string) {
+	q := r.URL.Query()
+
+	size := defaultPageSize
+	if v := q.Get("page[size]"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeParamError(w, "page[size]", fmt.Sprintf("The page size must be a number from 1 to %d.", maxPageSize))
+			return
+		}
+		size = n
+	}
+
+	index := func(param string) (int, bool) {
+		i := slices.IndexFunc(items, func(item T) bool { return id(item) == q.Get(param) })
+		if i < 0 {
+			writeParamError(w, param, "The page cursor is invalid.")
+		}
+		return i, i >= 0
+	}
+	start, end := 0, min(size, len(items))
+	switch {
+	case q.Has("page[after]"):
+		i, ok := index("page[after]")
+		if !ok {
+			return
+		}
+		start, end = i+1, min(i+1+size, len(items))
+	case q.Has("page[before]"):
+		i, ok := index("page[before]")
+		if !ok {
+			return
+		}
+		start, end = max(i-size, 0), i
+	}
+
+	resp := listResponse[T]{Data: append([]T{}, items[start:end]...)}
+	link := func(param string, item T) *string {
+		lq := url.Values{}
+		for k, v := range q {
+			if k != "page[after]" && k != "page[before]" {
+				lq[k] = v
+			}
+		}
+		lq.Set(param, id(item))
+		l := baseURL(r) + r.URL.Path + "?" + lq.Encode()
+		return &l
+	}
+	if end < len(items) {
+		resp.Links.Next = link("page[after]", items[end-1])
+	}
+	if start > 0 {
+		resp.Links.Prev = link("page[before]", items[start])
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request", "The request body could not be decoded: "+err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// sourceParam locates the cause of an error in a request.
+type sourceParam struct {
+	parameter string
+	pointer   string
+}
+
+func writeError(w http.ResponseWriter, status int, title, detail string, source *sourceParam) {
+	obj := oapi.ErrorObject{
+		Status: strconv.Itoa(status),
+		Title:  title,
+		Detail: detail,
+	}
+	if source != nil {
+		obj.Source = &struct {
+			Parameter *string `json:"parameter,omitempty"`
+			Pointer   *string `json:"pointer,omitempty"`
+		}{}
+		if source.parameter != "" {
+			obj.Source.Parameter = &source.parameter
+		}
+		if source.pointer != "" {
+			obj.Source.Pointer = &source.pointer
+		}
+	}
+	writeJSON(w, status, oapi.ErrorResponse{Errors: []oapi.ErrorObject{obj}})
+}
+
+func writeParamError(w http.ResponseWriter, param, detail string) {
+	writeError(w, http.StatusBadRequest, "Invalid Parameter", detail, &sourceParam{parameter: param})
+}
+
+func writeNotFound(w http.ResponseWriter, what string) {
+	writeError(w, http.StatusNotFound, "Not Found", "The "+what+" could not be found.", nil)
+}
+
+func newSecretKey() string {
+	var b [32]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
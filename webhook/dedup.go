@@ -0,0 +1,300 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store records the IDs of events which are being or have been handled. Up
+// retries delivery of an event until it succeeds, keeping the same event ID,
+// so a Store is used by the [Dispatcher] to handle each event at most once.
+// See [WithDedup].
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Claim records id, reporting false if it was already recorded. It must
+	// be atomic, so that concurrent deliveries of the same event cannot both
+	// claim it.
+	Claim(ctx context.Context, id string) (bool, error)
+
+	// Release removes id, so that a later delivery of the event is handled.
+	// It is called when an event could not be handled.
+	Release(ctx context.Context, id string) error
+}
+
+// WithDedup has the dispatcher claim each event ID in store before calling
+// the handler, ignoring events which were already claimed. If the handler
+// returns an error the claim is released, so that Up's retry is handled.
+//
+// As the claim is made before the handler is called, an event whose handler
+// is interrupted by the process exiting remains claimed. With a persistent
+// store such as [FileStore], Up's redelivery of that event is then ignored
+// until the claim expires. Handlers which must not miss such events should
+// record their own progress, or be used with a shorter time to live.
+func WithDedup(store Store) Option {
+	return func(d *Dispatcher) {
+		d.store = store
+	}
+}
+
+// MemoryStore is a [Store] which holds event IDs in memory, forgetting each
+// after a time to live.
+type MemoryStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	claimed   map[string]time.Time
+	lastPurge time.Time
+}
+
+// NewMemoryStore returns a MemoryStore which remembers event IDs for ttl. A
+// ttl of zero or less means IDs are never forgotten.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:       ttl,
+		claimed:   make(map[string]time.Time),
+		lastPurge: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, id string) (bool, error) {
+	return s.claim(id, time.Now()), nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, id)
+	return nil
+}
+
+// claim records id as claimed at the given time.
+func (s *MemoryStore) claim(id string, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.ttl > 0 && now.Sub(s.lastPurge) > s.ttl {
+		for k, t := range s.claimed {
+			if s.expired(t, now) {
+				delete(s.claimed, k)
+			}
+		}
+		s.lastPurge = now
+	}
+
+	if t, ok := s.claimed[id]; ok && !s.expired(t, now) {
+		return false
+	}
+	s.claimed[id] = at
+	return true
+}
+
+// snapshot returns a claim record for each unexpired ID.
+func (s *MemoryStore) snapshot() []fileStoreRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	records := make([]fileStoreRecord, 0, len(s.claimed))
+	for id, at := range s.claimed {
+		if !s.expired(at, now) {
+			records = append(records, fileStoreRecord{ID: id, At: at})
+		}
+	}
+	return records
+}
+
+// len returns the number of IDs held, including any not yet purged.
+func (s *MemoryStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.claimed)
+}
+
+func (s *MemoryStore) expired(claimed, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(claimed) > s.ttl
+}
+
+// FileStore is a [Store] which persists event IDs to a file, so that events
+// are not handled again after a restart. Its state is also held in memory.
+//
+// The file is in JSON Lines format, with each claim or release appended as a
+// line. Expired and released IDs are dropped when the file is opened, and
+// whenever the file has grown to more than twice the lines needed to hold
+// the IDs remembered.
+type FileStore struct {
+	mem *MemoryStore
+
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records int
+}
+
+// fileStoreCompactMin is the fewest lines at which a FileStore is compacted
+// while open.
+const fileStoreCompactMin = 1000
+
+type fileStoreRecord struct {
+	ID       string    `json:"id"`
+	At       time.Time `json:"at"`
+	Released bool      `json:"released,omitempty"`
+}
+
+// OpenFileStore opens the FileStore at path, creating the file if it does not
+// exist. Event IDs are remembered for ttl, or forever if ttl is zero or less.
+func OpenFileStore(path string, ttl time.Duration) (*FileStore, error) {
+	s := &FileStore{mem: NewMemoryStore(ttl), path: path}
+
+	if err := s.load(path); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the existing records at path into memory.
+func (s *FileStore) load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening dedup store: %w", err)
+	}
+	defer f.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec fileStoreRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// a partial line may be left by a crash mid-write
+			continue
+		}
+		if rec.Released {
+			delete(s.mem.claimed, rec.ID)
+		} else if !s.mem.expired(rec.At, now) {
+			s.mem.claimed[rec.ID] = rec.At
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading dedup store: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the file with only the unexpired IDs held in memory, and
+// continues appending to the new file. It must be called with s.mu held, or
+// before the store is shared.
+func (s *FileStore) compact() error {
+	records := s.mem.snapshot()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("error compacting dedup store: %w", err)
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error compacting dedup store: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return fail(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fail(err)
+	}
+
+	// the temporary file is now the store, so keep appending to it
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = tmp
+	s.records = len(records)
+	return nil
+}
+
+func (s *FileStore) Claim(ctx context.Context, id string) (bool, error) {
+	now := time.Now()
+	if !s.mem.claim(id, now) {
+		return false, nil
+	}
+	if err := s.append(fileStoreRecord{ID: id, At: now}); err != nil {
+		// not persisted, so don't treat it as claimed
+		_ = s.mem.Release(ctx, id)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FileStore) Release(ctx context.Context, id string) error {
+	_ = s.mem.Release(ctx, id)
+	return s.append(fileStoreRecord{ID: id, At: time.Now(), Released: true})
+}
+
+// append writes rec to the file, syncing it to disk.
+func (s *FileStore) append(rec fileStoreRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("error writing dedup store: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("error writing dedup store: %w", err)
+	}
+
+	s.records++
+	if s.records >= fileStoreCompactMin && s.records > 2*s.mem.len() {
+		// the record was written, and on failure the old file is intact and
+		// compaction is tried again after the next write
+		_ = s.compact()
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
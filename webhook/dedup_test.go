@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreCompactsWhileOpen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dedup.jsonl")
+	s, err := OpenFileStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Claim(ctx, "kept"); !ok || err != nil {
+		t.Fatalf("Claim(kept) = %v, %v", ok, err)
+	}
+	for i := range fileStoreCompactMin {
+		id := fmt.Sprint(i)
+		if _, err := s.Claim(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Release(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines >= fileStoreCompactMin {
+		t.Errorf("file has %d lines, want fewer than %d", lines, fileStoreCompactMin)
+	}
+
+	s, err = OpenFileStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if ok, _ := s.Claim(ctx, "kept"); ok {
+		t.Error("claim lost by compaction")
+	}
+	if ok, _ := s.Claim(ctx, "1"); !ok {
+		t.Error("released ID still claimed after compaction")
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// JournalEntry is a webhook request recorded in a [Journal].
+type JournalEntry struct {
+	ReceivedAt time.Time `json:"receivedAt"`
+	EventID    string    `json:"eventId"`
+
+	// EventType is kept as recorded, which may be a type outside the API
+	// such as [TransactionAmountChanged].
+	EventType string `json:"eventType"`
+
+	// Signature is the request's [SignatureHeader].
+	Signature string `json:"signature"`
+
+	// Body is the raw request body, kept byte for byte so that the signature
+	// can be verified again.
+	Body string `json:"body"`
+}
+
+// Journal is an append-only record of received webhook requests, so that
+// events can be replayed later with [Dispatcher.Replay]. See [WithJournal].
+//
+// The file is in JSON Lines format, with one [JournalEntry] per line.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenJournal opens the Journal at path for appending, creating the file if
+// it does not exist. A partial last line left by a crash mid-write is ended,
+// so that it is not joined to the next entry.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	if err := endLine(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	return &Journal{file: f}, nil
+}
+
+// endLine appends a newline to f unless it is empty or already ends with one.
+func endLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+// WithJournal has the handler record each request in j once its signature
+// has been verified and its event decoded, before the event is dispatched.
+// If the request cannot be recorded the handler responds with an error, so
+// that Up retries delivery.
+func WithJournal(j *Journal) HandlerOption {
+	return func(h *Handler) {
+		h.journal = j
+	}
+}
+
+// Record appends an entry for the request with the given body and signature,
+// carrying event.
+func (j *Journal) Record(receivedAt time.Time, body []byte, signature string, event *Event) error {
+	line, err := json.Marshal(JournalEntry{
+		ReceivedAt: receivedAt,
+		EventID:    event.Id,
+		EventType:  string(event.Type()),
+		Signature:  signature,
+		Body:       string(body),
+	})
+	if err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(line); err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// ErrInvalidJournalEntry is returned by [ReadJournal] for a line of a journal
+// which cannot be decoded, such as a partial line left by a crash mid-write.
+var ErrInvalidJournalEntry = errors.New("invalid journal entry")
+
+// ReadJournal returns an iterator over the entries of a journal read from r,
+// in the order they were recorded. Lines are not limited in length.
+//
+// A line which cannot be decoded yields an error wrapping
+// [ErrInvalidJournalEntry], after which iteration may continue with the next
+// line. Any other error ends the iteration.
+func ReadJournal(r io.Reader) iter.Seq2[JournalEntry, error] {
+	return func(yield func(JournalEntry, error) bool) {
+		br := bufio.NewReader(r)
+		for n := 1; ; n++ {
+			line, err := br.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				yield(JournalEntry{}, fmt.Errorf("error reading journal: %w", err))
+				return
+			}
+			if len(bytes.TrimSpace(line)) > 0 {
+				var entry JournalEntry
+				if jerr := json.Unmarshal(line, &entry); jerr != nil {
+					if !yield(JournalEntry{}, fmt.Errorf("error reading journal line %d: %w: %w", n, ErrInvalidJournalEntry, jerr)) {
+						return
+					}
+				} else if !yield(entry, nil) {
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}
+}
+
+// ReplayOptions selects the journal entries replayed by [Dispatcher.Replay].
+type ReplayOptions struct {
+	// Since and Until limit the entries to those received in [Since, Until).
+	// A zero value leaves that end of the range open.
+	Since time.Time
+	Until time.Time
+
+	// EventTypes limits the entries to those with one of the given event
+	// types. All types are replayed if it is empty.
+	EventTypes []oapi.WebhookEventTypeEnum
+
+	// SecretKey, if set, is used to verify the signature of each entry again.
+	// Entries which fail verification are not replayed.
+	SecretKey string
+}
+
+func (o ReplayOptions) match(entry JournalEntry) bool {
+	if !o.Since.IsZero() && entry.ReceivedAt.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !entry.ReceivedAt.Before(o.Until) {
+		return false
+	}
+	if len(o.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range o.EventTypes {
+		if entry.EventType == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay dispatches the events recorded in the journal at path which match
+// opts, in the order they were received, returning the number dispatched.
+// Replaying continues past events which fail; their errors are joined
+// together and returned.
+//
+// The journal records every delivery of an event, including Up's retries of
+// deliveries which failed, so an event may appear more than once. Each event
+// is dispatched only once per call, using its latest matching entry, at that
+// entry's position in the journal.
+//
+// Journal lines which cannot be decoded are logged and skipped.
+//
+// A dispatcher using [WithDedup] ignores events it has already handled, so
+// replaying to reprocess events normally uses a dispatcher without it.
+func (d *Dispatcher) Replay(ctx context.Context, path string, opts ReplayOptions) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening journal: %w", err)
+	}
+	defer f.Close()
+
+	// the first pass finds the latest matching entry of each event
+	var errs []error
+	latest := make(map[string]int)
+	i := 0
+	for entry, err := range ReadJournal(f) {
+		if errors.Is(err, ErrInvalidJournalEntry) {
+			d.logger.Warn("skipping journal entry", "err", err)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		i++
+		if !opts.match(entry) {
+			continue
+		}
+		if opts.SecretKey != "" && !Verify(opts.SecretKey, []byte(entry.Body), entry.Signature) {
+			errs = append(errs, fmt.Errorf("error replaying event %s: %w", entry.EventID, ErrInvalidSignature))
+			continue
+		}
+		latest[entry.EventID] = i
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("error reading journal: %w", err)
+	}
+
+	replayed := 0
+	i = 0
+	for entry, err := range ReadJournal(f) {
+		if errors.Is(err, ErrInvalidJournalEntry) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		i++
+		if latest[entry.EventID] != i {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		event, err := Decode([]byte(entry.Body))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error replaying event %s: %w", entry.EventID, err))
+			continue
+		}
+
+		d.logger.Info("replaying webhook event", "id", event.Id, "type", event.Type(), "receivedAt", entry.ReceivedAt)
+		replayed++
+		if err := d.Dispatch(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return replayed, errors.Join(errs...)
+}
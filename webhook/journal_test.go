@@ -0,0 +1,224 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/webhook"
+	"github.com/porjo/upgo/webhook/webhooktest"
+)
+
+// newJournal returns the path of a journal recording the given events, of
+// which the first delivery of retried fails.
+func newJournal(t *testing.T, retried, other string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := webhook.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	failed := false
+	d := webhook.NewDispatcher()
+	d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+		if ev.TransactionID() == retried && !failed {
+			failed = true
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+	sender := newTestServer(t, d, webhook.WithJournal(j))
+
+	ctx := context.Background()
+	deliveries := sender.SendUntilDelivered(ctx, webhooktest.TransactionCreated("webhook", retried), 3, 0)
+	if len(deliveries) != 2 || deliveries[1].StatusCode != http.StatusOK {
+		t.Fatalf("got %d deliveries, want 2 ending in success", len(deliveries))
+	}
+	if got := sender.Send(ctx, webhooktest.TransactionCreated("webhook", other)); got.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", got.StatusCode, http.StatusOK)
+	}
+	return path
+}
+
+func TestReplayDispatchesEachEventOnce(t *testing.T) {
+	path := newJournal(t, "retried", "other")
+
+	d := webhook.NewDispatcher()
+	var got []string
+	d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+		got = append(got, ev.TransactionID())
+		return nil
+	})
+
+	n, err := d.Replay(context.Background(), path, webhook.ReplayOptions{SecretKey: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"retried", "other"}; n != len(want) || !slices.Equal(got, want) {
+		t.Errorf("replayed %d events %v, want %v", n, got, want)
+	}
+}
+
+func TestJournalRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := webhook.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	d := webhook.NewDispatcher()
+	sender := newTestServer(t, d, webhook.WithJournal(j))
+	types := []oapi.WebhookEventTypeEnum{webhook.TransactionAmountChanged, "TRANSACTION_SOMETHING_NEW"}
+	for _, typ := range types {
+		if got := sender.Send(context.Background(), webhooktest.NewEvent(typ, "webhook", "transaction")); got.StatusCode != http.StatusOK {
+			t.Fatalf("got status %d, want %d", got.StatusCode, http.StatusOK)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []string
+	for entry, err := range webhook.ReadJournal(f) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, entry.EventType)
+	}
+	if want := []string{string(types[0]), string(types[1])}; !slices.Equal(got, want) {
+		t.Errorf("read event types %v, want %v", got, want)
+	}
+
+	var replayed []oapi.WebhookEventTypeEnum
+	d.OnTransactionAmountChanged(func(ctx context.Context, ev *webhook.Event) error {
+		replayed = append(replayed, ev.Type())
+		return nil
+	})
+	n, err := d.Replay(context.Background(), path, webhook.ReplayOptions{SecretKey: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// events of unknown types are replayed, and ignored by the dispatcher
+	if n != 2 || !slices.Equal(replayed, types[:1]) {
+		t.Errorf("replayed %d events, handled %v, want 2 and %v", n, replayed, types[:1])
+	}
+}
+
+func TestReplayLongAndInvalidLines(t *testing.T) {
+	body, _, err := webhooktest.Encode(testSecret, webhooktest.TransactionCreated("webhook", "long"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each '<' is escaped as \u003c in the journal, so the line is several
+	// times longer than the body
+	body = append(body[:len(body)-1], `,"padding":"`+strings.Repeat("<", webhook.DefaultMaxBodySize)+`"}`...)
+	sig := webhook.Sign(testSecret, body)
+	ev, err := webhook.Decode(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	if err := os.WriteFile(path, []byte("not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	j, err := webhook.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record(time.Now(), body, sig, ev); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// a partial line left by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"eventId":`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// an entry recorded after reopening the journal
+	after, afterSig, err := webhooktest.Encode(testSecret, webhooktest.TransactionCreated("webhook", "after"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterEv, err := webhook.Decode(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err = webhook.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Record(time.Now(), after, afterSig, afterEv); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries, invalid int
+	for _, err := range webhook.ReadJournal(f) {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidJournalEntry):
+			invalid++
+		case err != nil:
+			t.Fatal(err)
+		default:
+			entries++
+		}
+	}
+	if entries != 2 || invalid != 2 {
+		t.Errorf("read %d entries and %d invalid lines, want 2 and 2", entries, invalid)
+	}
+
+	d := webhook.NewDispatcher()
+	var got []string
+	d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+		got = append(got, ev.TransactionID())
+		return nil
+	})
+	n, err := d.Replay(context.Background(), path, webhook.ReplayOptions{SecretKey: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"long", "after"}; n != len(want) || !slices.Equal(got, want) {
+		t.Errorf("replayed %d events %v, want %v", n, got, want)
+	}
+}
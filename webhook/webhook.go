@@ -0,0 +1,418 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook receives events sent by Up to a webhook URL, verifying their
+// signature and dispatching them to typed handlers.
+//
+// Webhooks are created with [github.com/porjo/upgo.Client.CreateWebhook], which
+// returns the secret key used to sign events.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// SignatureHeader is the request header carrying the signature of an event.
+const SignatureHeader = "X-Up-Authenticity-Signature"
+
+// DefaultMaxBodySize is the largest request body accepted by a [Handler] by default.
+const DefaultMaxBodySize = 1 << 20
+
+// ErrInvalidSignature is returned when a request signature does not match its body.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// TransactionAmountChanged is the type of events raised when the amount of a
+// transaction changes. Up does not send these events; they are only raised by
+// the poller of [github.com/porjo/upgo.Client.NewPoller].
+//
+// As the type is not part of the API, its Valid method reports false.
+// [Decode], and so [Handler], accept it like any other event type, allowing
+// events raised by a poller to be forwarded to a Handler.
+const TransactionAmountChanged oapi.WebhookEventTypeEnum = "TRANSACTION_AMOUNT_CHANGED"
+
+// Sign returns the signature of body for the webhook with the given secret
+// key, being the hex encoded SHA-256 HMAC of body.
+func Sign(secretKey string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the valid signature of body for the
+// webhook with the given secret key. The comparison is made in constant time.
+func Verify(secretKey string, body []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Event is a webhook event, as received in an [oapi.WebhookEventCallback].
+type Event struct {
+	oapi.WebhookEventResource
+
+	// Transaction is the transaction the event relates to. It is only set
+	// when the [Dispatcher] is configured using [WithTransactionResolver] or
+	// the event was raised by a poller, and never for PING or
+	// TRANSACTION_DELETED events.
+	Transaction *oapi.TransactionResource
+
+	// Previous is the transaction as it was before the event. It is only set
+	// for events raised by the poller of
+	// [github.com/porjo/upgo.Client.NewPoller].
+	Previous *oapi.TransactionResource
+}
+
+// Type returns the type of the event.
+func (e *Event) Type() oapi.WebhookEventTypeEnum {
+	return e.Attributes.EventType
+}
+
+// TransactionID returns the identifier of the transaction the event relates
+// to, or an empty string for events which do not relate to a transaction
+// such as PING.
+func (e *Event) TransactionID() string {
+	if e.Relationships.Transaction == nil {
+		return ""
+	}
+	return e.Relationships.Transaction.Data.Id
+}
+
+// Decode decodes the body of a webhook request into an Event. Any event type
+// is accepted, so that events of types added to the API since this package
+// was written reach the [Dispatcher], which ignores them.
+func Decode(body []byte) (*Event, error) {
+	var cb oapi.WebhookEventCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return nil, fmt.Errorf("error decoding webhook event: %w", err)
+	}
+	return &Event{WebhookEventResource: cb.Data}, nil
+}
+
+// HandlerFunc handles an event. Returning an error causes the event to be
+// reported to Up as undelivered, so that it is retried later.
+type HandlerFunc func(ctx context.Context, event *Event) error
+
+// Option configures a [Dispatcher].
+type Option func(*Dispatcher)
+
+// WithLogger allows the user to define how log emitted by the dispatcher, and
+// any [Handler] using it, will be handled. Log is discarded otherwise.
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Dispatcher) {
+		d.logger = logger
+	}
+}
+
+// TransactionGetter fetches a transaction. It is implemented by
+// [github.com/porjo/upgo.Client].
+//
+// Errors from the API should implement an HTTPStatusCode() int method, as
+// [github.com/porjo/upgo.APIError] does, so that lookups which failed with a
+// permanent error are not retried.
+type TransactionGetter interface {
+	GetTransaction(ctx context.Context, id string) (*oapi.TransactionResource, error)
+}
+
+const (
+	defaultResolveTries = 4
+	defaultResolveDelay = 500 * time.Millisecond
+)
+
+// WithTransactionResolver has the dispatcher fetch the transaction each event
+// relates to using getter, setting [Event.Transaction] before calling the
+// handler. As a newly created transaction may not be readable immediately,
+// lookups which fail with HTTP 404, or with a transient error such as a
+// network error, HTTP 429 or HTTP 5xx, are retried, see [WithResolveRetry].
+// Other errors are returned at once. If the transaction
+// cannot be fetched the handler is not called and an error is returned so
+// that the event is retried later.
+func WithTransactionResolver(getter TransactionGetter) Option {
+	return func(d *Dispatcher) {
+		d.resolver = getter
+	}
+}
+
+// WithResolveRetry sets the number of attempts made to fetch a transaction
+// for [WithTransactionResolver], and the delay before the first retry which
+// doubles for each subsequent retry. The default is 4 attempts, starting with
+// a 500ms delay.
+//
+// These attempts are made in addition to any retries by the getter itself,
+// such as those of [github.com/porjo/upgo.WithRetryPolicy], which repeats
+// each attempt after transient errors.
+func WithResolveRetry(attempts int, delay time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.resolveTries = attempts
+		d.resolveDelay = delay
+	}
+}
+
+// Dispatcher calls the handler registered for the type of each event. Events
+// without a handler are ignored. Handlers may be registered at any time and
+// the Dispatcher is safe for concurrent use.
+type Dispatcher struct {
+	mu       sync.RWMutex
+	handlers map[oapi.WebhookEventTypeEnum]HandlerFunc
+
+	store Store
+
+	resolver     TransactionGetter
+	resolveTries int
+	resolveDelay time.Duration
+
+	logger *slog.Logger
+}
+
+// NewDispatcher returns a Dispatcher with no handlers registered.
+func NewDispatcher(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		handlers:     make(map[oapi.WebhookEventTypeEnum]HandlerFunc),
+		resolveTries: defaultResolveTries,
+		resolveDelay: defaultResolveDelay,
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Handle registers fn as the handler for events of type eventType, replacing
+// any existing handler.
+func (d *Dispatcher) Handle(eventType oapi.WebhookEventTypeEnum, fn HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[eventType] = fn
+}
+
+// OnPing registers fn as the handler for PING events.
+func (d *Dispatcher) OnPing(fn HandlerFunc) {
+	d.Handle(oapi.PING, fn)
+}
+
+// OnTransactionCreated registers fn as the handler for TRANSACTION_CREATED events.
+func (d *Dispatcher) OnTransactionCreated(fn HandlerFunc) {
+	d.Handle(oapi.TRANSACTIONCREATED, fn)
+}
+
+// OnTransactionSettled registers fn as the handler for TRANSACTION_SETTLED events.
+func (d *Dispatcher) OnTransactionSettled(fn HandlerFunc) {
+	d.Handle(oapi.TRANSACTIONSETTLED, fn)
+}
+
+// OnTransactionDeleted registers fn as the handler for TRANSACTION_DELETED events.
+func (d *Dispatcher) OnTransactionDeleted(fn HandlerFunc) {
+	d.Handle(oapi.TRANSACTIONDELETED, fn)
+}
+
+// OnTransactionAmountChanged registers fn as the handler for
+// [TransactionAmountChanged] events. These are raised by a poller, or decoded
+// by [Decode] from events it has forwarded, whose Previous transaction is then
+// not set.
+func (d *Dispatcher) OnTransactionAmountChanged(fn HandlerFunc) {
+	d.Handle(TransactionAmountChanged, fn)
+}
+
+// Dispatch calls the handler registered for the type of event, returning its error.
+func (d *Dispatcher) Dispatch(ctx context.Context, event *Event) error {
+	d.mu.RLock()
+	fn, ok := d.handlers[event.Type()]
+	d.mu.RUnlock()
+
+	if !ok {
+		d.logger.Debug("no handler for webhook event", "id", event.Id, "type", event.Type())
+		return nil
+	}
+
+	if d.store != nil {
+		claimed, err := d.store.Claim(ctx, event.Id)
+		if err != nil {
+			return fmt.Errorf("error claiming %s event %s: %w", event.Type(), event.Id, err)
+		}
+		if !claimed {
+			d.logger.Info("ignoring duplicate webhook event", "id", event.Id, "type", event.Type())
+			return nil
+		}
+	}
+
+	if err := d.handle(ctx, fn, event); err != nil {
+		if d.store != nil {
+			if relErr := d.store.Release(context.WithoutCancel(ctx), event.Id); relErr != nil {
+				d.logger.Error("error releasing webhook event", "id", event.Id, "err", relErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *Dispatcher) handle(ctx context.Context, fn HandlerFunc, event *Event) error {
+	if d.resolver != nil && event.Transaction == nil && event.Type() != oapi.TRANSACTIONDELETED && event.TransactionID() != "" {
+		t, err := d.resolveTransaction(ctx, event.TransactionID())
+		if err != nil {
+			return fmt.Errorf("error resolving transaction of %s event %s: %w", event.Type(), event.Id, err)
+		}
+		event.Transaction = t
+	}
+
+	d.logger.Info("handling webhook event", "id", event.Id, "type", event.Type())
+	if err := fn(ctx, event); err != nil {
+		return fmt.Errorf("error handling %s event %s: %w", event.Type(), event.Id, err)
+	}
+	return nil
+}
+
+// resolveTransaction fetches a transaction, retrying with a doubling delay as
+// a newly created transaction may not be readable immediately.
+func (d *Dispatcher) resolveTransaction(ctx context.Context, id string) (*oapi.TransactionResource, error) {
+	delay := d.resolveDelay
+	for attempt := 1; ; attempt++ {
+		t, err := d.resolver.GetTransaction(ctx, id)
+		if err == nil {
+			return t, nil
+		}
+		if attempt >= d.resolveTries || ctx.Err() != nil || !retryLookup(err) {
+			return nil, err
+		}
+
+		d.logger.Warn("retrying transaction lookup", "transactionID", id, "attempt", attempt, "delay", delay, "err", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// retryLookup reports whether a failed transaction lookup is worth retrying:
+// the transaction may not be readable yet, or the error is transient.
+func retryLookup(err error) bool {
+	var apiErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &apiErr) {
+		code := apiErr.HTTPStatusCode()
+		return code == http.StatusNotFound || code == http.StatusTooManyRequests || code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// HandlerOption configures a [Handler].
+type HandlerOption func(*Handler)
+
+// WithMaxBodySize limits the size of request bodies accepted by the handler.
+// The default is [DefaultMaxBodySize].
+func WithMaxBodySize(n int64) HandlerOption {
+	return func(h *Handler) {
+		h.maxBodySize = n
+	}
+}
+
+// Handler is an [http.Handler] which receives webhook events. Each request
+// has its [SignatureHeader] verified against the raw body before the event is
+// decoded and passed to the [Dispatcher].
+//
+// It responds with:
+//   - 200 once the event has been handled
+//   - 401 if the signature is missing or invalid
+//   - 400 if the body cannot be decoded
+//   - 500 if the event could not be recorded in the journal or the event
+//     handler returned an error, causing Up to retry delivery
+type Handler struct {
+	secretKey   string
+	dispatcher  *Dispatcher
+	maxBodySize int64
+	journal     *Journal
+}
+
+// NewHandler returns a Handler for the webhook with the given secret key,
+// passing events to d.
+func NewHandler(secretKey string, d *Dispatcher, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		secretKey:   secretKey,
+		dispatcher:  d,
+		maxBodySize: DefaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.dispatcher.logger
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		logger.Warn("error reading webhook request", "err", err)
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	signature := r.Header.Get(SignatureHeader)
+	if !Verify(h.secretKey, body, signature) {
+		logger.Warn("rejected webhook request", "err", ErrInvalidSignature, "remote", r.RemoteAddr)
+		http.Error(w, ErrInvalidSignature.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	event, err := Decode(body)
+	if err != nil {
+		logger.Warn("rejected webhook request", "err", err)
+		http.Error(w, "error decoding event", http.StatusBadRequest)
+		return
+	}
+
+	if h.journal != nil {
+		if err := h.journal.Record(time.Now(), body, signature, event); err != nil {
+			logger.Error("error recording webhook event", "id", event.Id, "err", err)
+			http.Error(w, "error recording event", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := h.dispatcher.Dispatch(r.Context(), event); err != nil {
+		logger.Error("webhook event failed", "err", err)
+		http.Error(w, "error handling event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
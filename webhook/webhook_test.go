@@ -0,0 +1,275 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/porjo/upgo"
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/webhook"
+	"github.com/porjo/upgo/webhook/webhooktest"
+)
+
+const testSecret = "secret"
+
+// newTestServer returns a server receiving events for d, and a sender
+// delivering signed events to it.
+func newTestServer(t *testing.T, d *webhook.Dispatcher, opts ...webhook.HandlerOption) *webhooktest.Sender {
+	t.Helper()
+	srv := httptest.NewServer(webhook.NewHandler(testSecret, d, opts...))
+	t.Cleanup(srv.Close)
+	return webhooktest.NewServerSender(srv, testSecret)
+}
+
+func TestHandlerIgnoresUnknownEventType(t *testing.T) {
+	d := webhook.NewDispatcher()
+	d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+		t.Errorf("handler called for %s event", ev.Type())
+		return nil
+	})
+	sender := newTestServer(t, d)
+
+	cb := webhooktest.NewEvent("TRANSACTION_SOMETHING_NEW", "webhook", "transaction")
+	got := sender.Send(context.Background(), cb)
+	if got.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d (%q), want %d", got.StatusCode, got.Body, http.StatusOK)
+	}
+}
+
+func TestDecodeUnknownEventType(t *testing.T) {
+	body, _, err := webhooktest.Encode(testSecret, webhooktest.NewEvent("TRANSACTION_SOMETHING_NEW", "webhook", "transaction"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := webhook.Decode(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := oapi.WebhookEventTypeEnum("TRANSACTION_SOMETHING_NEW"); ev.Type() != want {
+		t.Errorf("got type %q, want %q", ev.Type(), want)
+	}
+	if ev.TransactionID() != "transaction" {
+		t.Errorf("got transaction ID %q, want %q", ev.TransactionID(), "transaction")
+	}
+	if ev.Attributes.CreatedAt.IsZero() {
+		t.Error("creation time not decoded")
+	}
+}
+
+func TestHandlerTransactionAmountChanged(t *testing.T) {
+	if webhook.TransactionAmountChanged.Valid() {
+		t.Fatal("TransactionAmountChanged is a valid API event type")
+	}
+
+	d := webhook.NewDispatcher()
+	var got string
+	d.OnTransactionAmountChanged(func(ctx context.Context, ev *webhook.Event) error {
+		got = ev.TransactionID()
+		return nil
+	})
+	sender := newTestServer(t, d)
+
+	cb := webhooktest.NewEvent(webhook.TransactionAmountChanged, "webhook", "transaction")
+	res := sender.Send(context.Background(), cb)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d (%q), want %d", res.StatusCode, res.Body, http.StatusOK)
+	}
+	if got != "transaction" {
+		t.Errorf("handler got transaction ID %q, want %q", got, "transaction")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"data":{}}`)
+	sig := webhook.Sign(testSecret, body)
+
+	tests := []struct {
+		name      string
+		secretKey string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", testSecret, body, sig, true},
+		{"uppercase hex", testSecret, body, strings.ToUpper(sig), true},
+		{"missing", testSecret, body, "", false},
+		{"tampered body", testSecret, []byte(`{"data":{"id":"x"}}`), sig, false},
+		{"tampered signature", testSecret, body, sig[:len(sig)-1] + "0", false},
+		{"truncated", testSecret, body, sig[:32], false},
+		{"not hex", testSecret, body, "not a signature", false},
+		{"wrong secret", "other", body, sig, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webhook.Verify(tt.secretKey, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	body, sig, err := webhooktest.Encode(testSecret, webhooktest.TransactionCreated("webhook", "transaction"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Replace(body, []byte("transaction"), []byte("tampered"), 1)
+
+	tests := []struct {
+		name       string
+		body       []byte
+		signature  string
+		wantStatus int
+		wantCalled bool
+	}{
+		{"valid signature", body, sig, http.StatusOK, true},
+		{"missing signature", body, "", http.StatusUnauthorized, false},
+		{"tampered body", tampered, sig, http.StatusUnauthorized, false},
+		{"tampered signature", body, webhook.Sign("other", body), http.StatusUnauthorized, false},
+		{"malformed body", []byte("{"), webhook.Sign(testSecret, []byte("{")), http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := webhook.NewDispatcher()
+			called := false
+			d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+				called = true
+				if ev.TransactionID() != "transaction" {
+					t.Errorf("got transaction ID %q, want %q", ev.TransactionID(), "transaction")
+				}
+				return nil
+			})
+			sender := newTestServer(t, d)
+
+			got := sender.SendRaw(context.Background(), tt.body, tt.signature)
+			if got.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d (%q), want %d", got.StatusCode, got.Body, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("handler called = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestHandlerMethodNotAllowed(t *testing.T) {
+	sender := newTestServer(t, webhook.NewDispatcher())
+
+	resp, err := sender.Client.Get(sender.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+	if allow := resp.Header.Get("Allow"); allow != http.MethodPost {
+		t.Errorf("got Allow %q, want %q", allow, http.MethodPost)
+	}
+}
+
+func TestHandlerFailedHandler(t *testing.T) {
+	d := webhook.NewDispatcher()
+	d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+		return errors.New("handler failed")
+	})
+	sender := newTestServer(t, d)
+
+	got := sender.Send(context.Background(), webhooktest.TransactionCreated("webhook", "transaction"))
+	if got.StatusCode != http.StatusInternalServerError || got.Status != oapi.BADRESPONSECODE {
+		t.Errorf("got status %d (%s), want %d", got.StatusCode, got.Status, http.StatusInternalServerError)
+	}
+}
+
+// fakeGetter returns each of errs in turn, and then a transaction.
+type fakeGetter struct {
+	errs  []error
+	calls int
+}
+
+func (g *fakeGetter) GetTransaction(ctx context.Context, id string) (*oapi.TransactionResource, error) {
+	g.calls++
+	if g.calls <= len(g.errs) {
+		return nil, g.errs[g.calls-1]
+	}
+	return &oapi.TransactionResource{Id: id}, nil
+}
+
+func TestResolveTransactionRetry(t *testing.T) {
+	notFound := fmt.Errorf("error getting transaction: %w", &upgo.APIError{StatusCode: http.StatusNotFound})
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"found", nil, 1, nil},
+		{"not found then found", []error{notFound, notFound}, 3, nil},
+		{"server error then found", []error{&upgo.APIError{StatusCode: http.StatusBadGateway}}, 2, nil},
+		{"network error then found", []error{&net.OpError{Op: "dial", Err: errors.New("connection refused")}}, 2, nil},
+		{"not found every attempt", []error{notFound, notFound, notFound, notFound}, 4, upgo.ErrNotFound},
+		{"unauthorized", []error{&upgo.APIError{StatusCode: http.StatusUnauthorized}}, 1, upgo.ErrUnauthorized},
+		{"other error", []error{errors.New("broken")}, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &fakeGetter{errs: tt.errs}
+			d := webhook.NewDispatcher(webhook.WithTransactionResolver(g), webhook.WithResolveRetry(4, time.Millisecond))
+			var got *oapi.TransactionResource
+			d.OnTransactionCreated(func(ctx context.Context, ev *webhook.Event) error {
+				got = ev.Transaction
+				return nil
+			})
+
+			ev, err := webhook.Decode(mustEncode(t, webhooktest.TransactionCreated("webhook", "transaction")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = d.Dispatch(context.Background(), ev)
+			if g.calls != tt.wantCalls {
+				t.Errorf("getter called %d times, want %d", g.calls, tt.wantCalls)
+			}
+			wantOK := tt.wantCalls > len(tt.errs)
+			if wantOK && (err != nil || got == nil || got.Id != "transaction") {
+				t.Errorf("got transaction %v and error %v, want transaction resolved", got, err)
+			}
+			if !wantOK && (err == nil || got != nil) {
+				t.Errorf("got transaction %v and error %v, want error", got, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func mustEncode(t *testing.T, cb *oapi.WebhookEventCallback) []byte {
+	t.Helper()
+	body, _, err := webhooktest.Encode(testSecret, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
@@ -0,0 +1,273 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooktest builds, signs and delivers synthetic webhook events, so
+// that webhook receivers can be tested without a real delivery from Up.
+//
+// Events are sent as Up sends them: a POST of an [oapi.WebhookEventCallback]
+// signed with the webhook's secret key in the
+// [github.com/porjo/upgo/webhook.SignatureHeader] header.
+package webhooktest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/porjo/upgo/oapi"
+	"github.com/porjo/upgo/webhook"
+)
+
+// apiURL is the API server URL used in resource links.
+const apiURL = "https://api.up.com.au/api/v1"
+
+// NewEvent returns an event of type eventType sent to the webhook identified
+// by webhookID, with a random event ID and the current time. The event
+// relates to the transaction identified by transactionID, unless it is empty.
+func NewEvent(eventType oapi.WebhookEventTypeEnum, webhookID, transactionID string) *oapi.WebhookEventCallback {
+	var ev oapi.WebhookEventResource
+	ev.Type = "webhook-events"
+	ev.Id = NewID()
+	ev.Attributes.EventType = eventType
+	ev.Attributes.CreatedAt = time.Now().Truncate(time.Second)
+
+	ev.Relationships.Webhook.Data.Type = "webhooks"
+	ev.Relationships.Webhook.Data.Id = webhookID
+	ev.Relationships.Webhook.Links = &struct {
+		Related string `json:"related"`
+	}{Related: apiURL + "/webhooks/" + webhookID}
+
+	if transactionID != "" {
+		rel := &struct {
+			Data struct {
+				Id   string `json:"id"`
+				Type string `json:"type"`
+			} `json:"data"`
+			Links *struct {
+				Related string `json:"related"`
+			} `json:"links,omitempty"`
+		}{}
+		rel.Data.Type = "transactions"
+		rel.Data.Id = transactionID
+		// a deleted transaction can no longer be fetched, so is not linked
+		if eventType != oapi.TRANSACTIONDELETED {
+			rel.Links = &struct {
+				Related string `json:"related"`
+			}{Related: apiURL + "/transactions/" + transactionID}
+		}
+		ev.Relationships.Transaction = rel
+	}
+
+	return &oapi.WebhookEventCallback{Data: ev}
+}
+
+// Ping returns a PING event sent to the webhook identified by webhookID.
+func Ping(webhookID string) *oapi.WebhookEventCallback {
+	return NewEvent(oapi.PING, webhookID, "")
+}
+
+// TransactionCreated returns a TRANSACTION_CREATED event for the transaction
+// identified by transactionID.
+func TransactionCreated(webhookID, transactionID string) *oapi.WebhookEventCallback {
+	return NewEvent(oapi.TRANSACTIONCREATED, webhookID, transactionID)
+}
+
+// TransactionSettled returns a TRANSACTION_SETTLED event for the transaction
+// identified by transactionID.
+func TransactionSettled(webhookID, transactionID string) *oapi.WebhookEventCallback {
+	return NewEvent(oapi.TRANSACTIONSETTLED, webhookID, transactionID)
+}
+
+// TransactionDeleted returns a TRANSACTION_DELETED event for the transaction
+// identified by transactionID.
+func TransactionDeleted(webhookID, transactionID string) *oapi.WebhookEventCallback {
+	return NewEvent(oapi.TRANSACTIONDELETED, webhookID, transactionID)
+}
+
+// NewID returns a random identifier in the UUID format used by Up.
+func NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Encode returns the JSON body of cb and its signature for the webhook with
+// the given secret key.
+func Encode(secretKey string, cb *oapi.WebhookEventCallback) (body []byte, signature string, err error) {
+	body, err = json.Marshal(cb)
+	if err != nil {
+		return nil, "", fmt.Errorf("error encoding webhook event: %w", err)
+	}
+	return body, webhook.Sign(secretKey, body), nil
+}
+
+// NewRequest returns a signed request delivering cb to url, as Up would. It
+// can be passed directly to a handler's ServeHTTP method.
+func NewRequest(ctx context.Context, url, secretKey string, cb *oapi.WebhookEventCallback) (*http.Request, error) {
+	body, sig, err := Encode(secretKey, cb)
+	if err != nil {
+		return nil, err
+	}
+	return newRequest(ctx, url, body, sig)
+}
+
+func newRequest(ctx context.Context, url string, body []byte, signature string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(webhook.SignatureHeader, signature)
+	}
+	return req, nil
+}
+
+// Delivery is the outcome of a single attempt to deliver an event.
+type Delivery struct {
+	// Attempt counts the deliveries of the event, starting at 1.
+	Attempt int
+
+	// Status classifies the delivery as Up does in its delivery logs.
+	Status oapi.WebhookDeliveryStatusEnum
+
+	// StatusCode and Body are the response to the delivery. They are unset if
+	// the delivery was UNDELIVERABLE, in which case Err is set.
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+// Sender delivers signed events to a webhook URL.
+type Sender struct {
+	URL       string
+	SecretKey string
+
+	// Client sends the requests. It defaults to [http.DefaultClient].
+	Client *http.Client
+}
+
+// NewSender returns a Sender delivering events to url, signed with secretKey.
+func NewSender(url, secretKey string) *Sender {
+	return &Sender{URL: url, SecretKey: secretKey}
+}
+
+// NewServerSender returns a Sender delivering events to the root of srv,
+// signed with secretKey.
+func NewServerSender(srv *httptest.Server, secretKey string) *Sender {
+	return &Sender{URL: srv.URL, SecretKey: secretKey, Client: srv.Client()}
+}
+
+// Send delivers cb once.
+func (s *Sender) Send(ctx context.Context, cb *oapi.WebhookEventCallback) Delivery {
+	body, sig, err := Encode(s.SecretKey, cb)
+	if err != nil {
+		return Delivery{Attempt: 1, Status: oapi.UNDELIVERABLE, Err: err}
+	}
+	return s.send(ctx, 1, body, sig)
+}
+
+// SendRaw delivers body with the given signature, which may be empty or
+// wrong in order to test how invalid requests are rejected.
+func (s *Sender) SendRaw(ctx context.Context, body []byte, signature string) Delivery {
+	return s.send(ctx, 1, body, signature)
+}
+
+// SendUntilDelivered delivers cb as Up does, retrying with a doubling delay
+// until a 2xx response is received or attempts have been made. Every attempt
+// carries the same event ID. The delivery of each attempt is returned.
+func (s *Sender) SendUntilDelivered(ctx context.Context, cb *oapi.WebhookEventCallback, attempts int, delay time.Duration) []Delivery {
+	body, sig, err := Encode(s.SecretKey, cb)
+	if err != nil {
+		return []Delivery{{Attempt: 1, Status: oapi.UNDELIVERABLE, Err: err}}
+	}
+
+	var deliveries []Delivery
+	for attempt := 1; attempt <= attempts; attempt++ {
+		d := s.send(ctx, attempt, body, sig)
+		deliveries = append(deliveries, d)
+		if d.Status == oapi.DELIVERED || attempt == attempts {
+			break
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return deliveries
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+	return deliveries
+}
+
+// SendDuplicates delivers cb n times regardless of the response, as happens
+// when Up does not receive a response which was sent.
+func (s *Sender) SendDuplicates(ctx context.Context, cb *oapi.WebhookEventCallback, n int) []Delivery {
+	body, sig, err := Encode(s.SecretKey, cb)
+	if err != nil {
+		return []Delivery{{Attempt: 1, Status: oapi.UNDELIVERABLE, Err: err}}
+	}
+
+	deliveries := make([]Delivery, 0, n)
+	for attempt := 1; attempt <= n; attempt++ {
+		deliveries = append(deliveries, s.send(ctx, attempt, body, sig))
+	}
+	return deliveries
+}
+
+func (s *Sender) send(ctx context.Context, attempt int, body []byte, signature string) Delivery {
+	d := Delivery{Attempt: attempt, Status: oapi.UNDELIVERABLE}
+
+	req, err := newRequest(ctx, s.URL, body, signature)
+	if err != nil {
+		d.Err = err
+		return d
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		d.Err = err
+		return d
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		d.Err = err
+		return d
+	}
+
+	d.StatusCode = resp.StatusCode
+	d.Body = string(respBody)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		d.Status = oapi.DELIVERED
+	} else {
+		d.Status = oapi.BADRESPONSECODE
+	}
+	return d
+}
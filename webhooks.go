@@ -0,0 +1,136 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/porjo/upgo/oapi"
+)
+
+// Limits on webhook attributes, as documented on [oapi.WebhookInputResource].
+const (
+	MaxWebhookURLLength         = 300
+	MaxWebhookDescriptionLength = 64
+)
+
+// ErrInvalidWebhook is returned by [Client.CreateWebhook] when the webhook
+// fails validation, before any request is made.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// CreatedWebhook is a newly created webhook.
+type CreatedWebhook struct {
+	Webhook oapi.WebhookResource
+
+	// SecretKey is the key used to sign the events sent to the webhook, see
+	// [oapi.WebhookResource]. It is returned only once, upon creation of the
+	// webhook, so it must be stored now. If lost, the webhook must be deleted
+	// and a new one created.
+	SecretKey string
+}
+
+// CreateWebhook creates a webhook which posts events to webhookURL. The
+// description is optional. The returned SecretKey must be stored, as the API
+// never returns it again.
+//
+// An error matching [ErrInvalidWebhook] is returned if webhookURL is not an
+// HTTP or HTTPS URL, or either argument exceeds its length limit.
+func (c *Client) CreateWebhook(ctx context.Context, webhookURL, description string) (*CreatedWebhook, error) {
+	c.logger.Info("CreateWebhook", "url", webhookURL)
+	if err := validateWebhook(webhookURL, description); err != nil {
+		return nil, err
+	}
+
+	var input oapi.WebhookInputResource
+	input.Attributes.Url = webhookURL
+	if description != "" {
+		input.Attributes.Description = &description
+	}
+
+	resp, err := c.upClient.PostWebhooksWithResponse(ctx, oapi.CreateWebhookRequest{Data: input})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayload("creating webhook", http.StatusCreated, resp.StatusCode(), resp.Body, resp.JSON201 != nil); err != nil {
+		return nil, err
+	}
+
+	created := &CreatedWebhook{Webhook: resp.JSON201.Data}
+	if key := created.Webhook.Attributes.SecretKey; key != nil {
+		created.SecretKey = *key
+	} else {
+		c.logger.Warn("created webhook has no secret key", "id", created.Webhook.Id)
+	}
+	return created, nil
+}
+
+// ListWebhooks returns all webhooks. Pagination behaves as for [Client.GetTransactions].
+func (c *Client) ListWebhooks(ctx context.Context, opts ...PageOption) ([]oapi.WebhookResource, error) {
+	c.logger.Info("ListWebhooks")
+	return collect(ctx, c.WebhooksPager(nil), newPageConfig(opts))
+}
+
+// DeleteWebhook deletes the webhook identified by id.
+// An error matching [ErrNotFound] is returned if there is no such webhook.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	c.logger.Info("DeleteWebhook", "id", id)
+	resp, err := c.upClient.DeleteWebhooksIdWithResponse(ctx, id)
+	if err != nil {
+		return err
+	}
+	return checkNoContent("deleting webhook "+id, resp.StatusCode(), resp.Body)
+}
+
+// PingWebhook sends a PING event to the webhook identified by id, returning the event sent.
+func (c *Client) PingWebhook(ctx context.Context, id string) (*oapi.WebhookEventResource, error) {
+	c.logger.Info("PingWebhook", "id", id)
+	resp, err := c.upClient.PostWebhooksWebhookIdPingWithResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayload("pinging webhook "+id, http.StatusCreated, resp.StatusCode(), resp.Body, resp.JSON201 != nil); err != nil {
+		return nil, err
+	}
+	return &resp.JSON201.Data, nil
+}
+
+// WebhookLogs returns the delivery logs of the webhook identified by id, most recent first.
+// Pagination behaves as for [Client.GetTransactions].
+func (c *Client) WebhookLogs(ctx context.Context, id string, opts ...PageOption) ([]oapi.WebhookDeliveryLogResource, error) {
+	c.logger.Info("WebhookLogs", "id", id)
+	return collect(ctx, c.WebhookLogsPager(id, nil), newPageConfig(opts))
+}
+
+func validateWebhook(webhookURL, description string) error {
+	if n := utf8.RuneCountInString(webhookURL); n > MaxWebhookURLLength {
+		return fmt.Errorf("%w: URL is %d characters, exceeding the limit of %d", ErrInvalidWebhook, n, MaxWebhookURLLength)
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: URL %q is not an HTTP or HTTPS URL", ErrInvalidWebhook, webhookURL)
+	}
+	if n := utf8.RuneCountInString(description); n > MaxWebhookDescriptionLength {
+		return fmt.Errorf("%w: description is %d characters, exceeding the limit of %d", ErrInvalidWebhook, n, MaxWebhookDescriptionLength)
+	}
+	return nil
+}